@@ -3,6 +3,7 @@ package ast
 import "github.com/seal-script/sealing/utils"
 
 type Location = utils.Location
+type Span = utils.Span
 
 type Node interface {
 	// Locate() returns the position associated with the node as follows:
@@ -14,21 +15,41 @@ type Node interface {
 	//    associated with that production; usually the left-most one
 	//    ('[' for IndexExpr, 'if' for IfStmt, etc.)
 	Locate() Location
+	// Span() returns the byte range of the source the node was built from.
+	Span() Span
+	SetSpan(loc Location, span Span)
+	// Doc() returns the doc comment attached to the node, or nil.
+	Doc() *Comment
+	SetDoc(doc *Comment)
 	aNode() // Just for constraint... golang hack!
 }
 
 type node struct {
-	// commented out for now since not yet used
-	// doc  *Comment // nil means no comment(s) attached
+	doc      *Comment // nil means no comment(s) attached
+	span     Span
 	Location Location
 }
 
 func (n *node) Locate() Location { return n.Location }
-func (*node) aNode()             {}
+func (n *node) Span() Span       { return n.span }
+func (n *node) SetSpan(loc Location, span Span) {
+	n.Location = loc
+	n.span = span
+}
+func (n *node) Doc() *Comment       { return n.doc }
+func (n *node) SetDoc(doc *Comment) { n.doc = doc }
+func (*node) aNode()                {}
+
+// A doc comment, i.e. `-- | ...` or `{-| ... -}`,
+// with the comment markers stripped.
+type Comment struct {
+	Text string
+}
 
 // package PkgName; DeclList[0], DeclList[1], ...
 type File struct {
 	// Pragma   Pragma
+	Module   *ModuleDecl // nil means no module header
 	PkgName  *Name
 	DeclList []Decl
 	EOF      Location
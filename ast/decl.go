@@ -15,18 +15,35 @@ type (
 	//              Path
 	// LocalPkgName Path
 	// import Data.Functor (fmap)
+	// import Data.Functor hiding (fmap)
+	// import qualified Data.Map as M
 	ImportDecl struct {
-		Alias *Name
-		Path  string
+		Alias     *Name // nil means no `as` clause
+		Path      string
+		Qualified bool
+		Hiding    bool          // Items are the names not imported
+		Items     []*ExportSpec // same forms as an export list, nil means everything
 		decl
 	}
 
+	// module Name (ExportList[0], ExportList[1], ...)
+	// module example (fact, List(..), Category(..))
 	ModuleDecl struct {
 		Name       *Name
-		ExportList []Expr
+		ExportList []*ExportSpec // nil means everything is exported
 		decl
 	}
 
+	// Name
+	// Name(..)
+	// Name(Members[0], Members[1], ...)
+	ExportSpec struct {
+		Name    *Name
+		All     bool    // Name(..)
+		Members []*Name // constructors or methods listed explicitly
+		node
+	}
+
 	// Name Type
 	// double : Int -> Int
 	// List : Type -> Type
@@ -49,24 +66,48 @@ type (
 	//     double x = x * x
 	//     x = double a
 	// } in x + 1
+	//
+	// The consecutive equations of a function are its clauses, tried
+	// in order, and its signature is its Type:
+	// fact : Int -> Int
+	// fact 0 = 1
+	// fact n = n * fact (n - 1)
 	FuncDecl struct {
-		Name   *Name
-		Type   Type
-		Params []Pattern
-		Body   Expr // Purely functional! nil means no body (forward declaration)
+		Name    *Name
+		Type    Type // nil means no signature
+		Clauses []*Clause
 		decl
 	}
 
-	// data Nat {
-	//     Zero : Nat
-	//     Succ : Nat -> Nat
+	// Name Params[0] Params[1] ... = Body
+	// fact 0 = 1
+	Clause struct {
+		Params []Pattern
+		Body   Expr // Purely functional!
+		node
+	}
+
+	// enum Name Params[0] Params[1] ... { Cons[0]; Cons[1]; ... }
+	// enum List a {
+	//     Nil  : List a
+	//     (::) : a -> List a -> List a
+	// }
+	// enum Person {
+	//     New { id : Int, name : String }
+	//     OfId Int
 	// }
+	//
+	// Every constructor gets its full signature: `OfId Int` is
+	// declared as `OfId : Int -> Person`, and the record constructor
+	// as `New : { id : Int, name : String } -> Person`.
 	EnumDecl struct {
-		Name *Name
-		Cons []TypeDecl
+		Name   *Name
+		Params []Field // type parameters, Type is nil unless given a kind
+		Cons   []TypeDecl
 		decl
 	}
 
+	// seal Context => Name Params[0] Params[1] ... { Fields; Defaults }
 	// seal Monoid a {
 	//     Empty : a
 	//     (<>)  : a -> a -> a
@@ -76,32 +117,98 @@ type (
 	//     map : (a -> b) -> f a -> f b
 	//     (<$>) = map
 	// }
+	//
+	// seal Semi a => Monoid a {
+	//     empty : a
+	// }
 	SealDecl struct {
-		Name   *Name
-		fields []TypeDecl
+		Context  []Field // superclasses, anonymous fields such as `Semi a`
+		Name     *Name
+		Params   []Field // Type is nil unless given a kind
+		Fields   []TypeDecl
+		Defaults []*FuncDecl // default definitions of some of the fields
+		decl
+	}
+
+	// impl Name : Context => Seal Args[0] Args[1] ... { Methods }
+	// impl Seal Args[0] Args[1] ... = Value
+	// impl Category (->) {
+	//     id = \a -> a
+	//     (~) = (>>)
+	// }
+	// impl (a : Type) => Semi (List a) {
+	//     xs <> ys = xs ++ ys
+	// }
+	// impl ListFunctor : Functor List { ... }
+	// impl Person = tom
+	ImplDecl struct {
+		Name    *Name   // nil means an anonymous instance
+		Context []Field // binders such as `(a : Type)` and constraints such as `Eq a`
+		Seal    *Name
+		Args    []Type
+		Methods []*FuncDecl
+		Value   Expr // the instance as a single value, nil if given by Methods
+		decl
+	}
+
+	// infixl Prec Ops[0], Ops[1], ...
+	// infixr 5 ::, ++
+	// infixl 7 `div`
+	FixityDecl struct {
+		Assoc Assoc
+		Prec  int // from 0 to 9
+		Ops   []*Name
+		decl
+	}
+
+	// Placeholder for a declaration that failed to parse
+	// correctly and where we can't provide a better node.
+	BadDecl struct {
 		decl
 	}
 )
 
+// The associativity of an infix operator
+type Assoc int
+
+const (
+	InfixLeft  Assoc = iota // infixl
+	InfixRight              // infixr
+	InfixNone               // infix, not associative
+)
+
+func (assoc Assoc) String() string {
+	switch assoc {
+	case InfixLeft:
+		return "infixl"
+	case InfixRight:
+		return "infixr"
+	default:
+		return "infix"
+	}
+}
+
 type decl struct{ node }
 
 func (*decl) aDecl() {}
 
 // Format declarations
 func (funcDecl *FuncDecl) String() string {
+	clauses := ""
+	for _, clause := range funcDecl.Clauses {
+		clauses += fmt.Sprintf("\n\t\t%v = %s : %v,", clause.Params, clause.Body, reflect.TypeOf(clause.Body))
+	}
 	return fmt.Sprintf(
 		`Function Decl {
 	Name: %s,
 	Type: %s : %v,
-	Params: %s,
-	Body: %s : %v,
+	Clauses: {%s
+	},
 }`,
 		funcDecl.Name,
 		funcDecl.Type,
 		reflect.TypeOf(funcDecl.Type),
-		funcDecl.Params,
-		funcDecl.Body,
-		reflect.TypeOf(funcDecl.Body),
+		clauses,
 	)
 }
 
@@ -115,3 +222,66 @@ func (typeDecl *TypeDecl) String() string {
 		typeDecl.Type,
 	)
 }
+
+func (enumDecl *EnumDecl) String() string {
+	cons := ""
+	for _, con := range enumDecl.Cons {
+		cons += fmt.Sprintf("\n\t\t%s : %s,", con.Name, con.Type)
+	}
+	return fmt.Sprintf(
+		`Enum Decl {
+	Name: %s,
+	Params: %d,
+	Cons: {%s
+	},
+}`,
+		enumDecl.Name,
+		len(enumDecl.Params),
+		cons,
+	)
+}
+
+func (sealDecl *SealDecl) String() string {
+	fields := ""
+	for _, field := range sealDecl.Fields {
+		fields += fmt.Sprintf("\n\t\t%s : %s,", field.Name, field.Type)
+	}
+	return fmt.Sprintf(
+		`Seal Decl {
+	Context: %d,
+	Name: %s,
+	Params: %d,
+	Fields: {%s
+	},
+	Defaults: %d,
+}`,
+		len(sealDecl.Context),
+		sealDecl.Name,
+		len(sealDecl.Params),
+		fields,
+		len(sealDecl.Defaults),
+	)
+}
+
+func (implDecl *ImplDecl) String() string {
+	methods := ""
+	for _, method := range implDecl.Methods {
+		methods += fmt.Sprintf("\n\t\t%s,", method.Name)
+	}
+	return fmt.Sprintf(
+		`Impl Decl {
+	Name: %v,
+	Context: %d,
+	Seal: %s %v,
+	Methods: {%s
+	},
+	Value: %v,
+}`,
+		implDecl.Name,
+		len(implDecl.Context),
+		implDecl.Seal,
+		implDecl.Args,
+		methods,
+		implDecl.Value,
+	)
+}
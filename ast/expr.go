@@ -1,6 +1,12 @@
 package ast
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 type typeInfo[T any] interface {
 	SetTypeInfo(T)
@@ -14,10 +20,6 @@ type (
 		aExpr() // hack again
 	}
 
-	Pattern interface {
-		Unify(expr Expr) map[*Name]Expr
-	}
-
 	CallExpr struct {
 		Fun     Expr
 		ArgList []Expr // nil means no arguments
@@ -26,6 +28,163 @@ type (
 		// atype
 	}
 
+	// Exprs[0] Ops[0] Exprs[1] Ops[1] ... Exprs[n]
+	// fact (n - 1) + fact (n - 2)
+	//
+	// A chain of infix applications as written, before the fixities
+	// of its operators are known. Resolving the operators turns it
+	// into nested CallExprs, e.g. `a + b` into `(+) a b`.
+	InfixExpr struct {
+		Exprs []Expr
+		Ops   []*Name
+		expr
+	}
+
+	// - X
+	// Only found in an InfixExpr, where it becomes `negate X`.
+	NegExpr struct {
+		X Expr
+		expr
+	}
+
+	// (Op Right), a right section
+	// (Left Op), a left section
+	// (<> x)
+	// (x <>)
+	SectionExpr struct {
+		Left  Expr // nil in a right section
+		Op    *Name
+		Right Expr // nil in a left section
+		expr
+	}
+
+	// \Params[0] Params[1] ... -> Body
+	// \a -> a
+	LambdaExpr struct {
+		Params []Pattern
+		Body   Expr
+		expr
+	}
+
+	// let { Decls } in Body
+	// let x = a * a in x + 1
+	LetExpr struct {
+		Decls []Decl // TypeDecls and FuncDecls
+		Body  Expr
+		expr
+	}
+
+	// Body where { Decls }
+	// x + 1 where x = a * a
+	//
+	// Only found as the body of an equation or of a case alternative.
+	WhereExpr struct {
+		Body  Expr
+		Decls []Decl // TypeDecls and FuncDecls
+		expr
+	}
+
+	// if Cond then Then else Else
+	IfExpr struct {
+		Cond Expr
+		Then Expr
+		Else Expr
+		expr
+	}
+
+	// case X of { Alts[0]; Alts[1]; ... }
+	// case xs of
+	//     Nil -> ys
+	//     (x :: xs) -> x :: (xs ++ ys)
+	CaseExpr struct {
+		X    Expr
+		Alts []*CaseAlt
+		expr
+	}
+
+	// do { Stmts[0]; Stmts[1]; ... }
+	// do
+	//     ref <- Ref.new empty
+	//     let y = f x
+	//     Ref.get ref
+	//
+	// The last statement is an ExprStmt.
+	DoExpr struct {
+		Stmts []Stmt
+		expr
+	}
+
+	// Pattern -> Body
+	CaseAlt struct {
+		Pattern Pattern
+		Body    Expr
+		node
+	}
+
+	// | Alts[0] | Alts[1] ...
+	// | n < 2     = n
+	// | otherwise = fact (n - 1) + fact (n - 2)
+	//
+	// Only found as the body of an equation or of a case alternative,
+	// possibly under a WhereExpr. When no guard holds, the next clause
+	// or alternative is tried.
+	GuardedExpr struct {
+		Alts []*GuardedAlt
+		expr
+	}
+
+	// | Guards[0], Guards[1], ... = Body
+	// | Just x <- lookup k m, x > 0 = x
+	GuardedAlt struct {
+		Guards []*Guard
+		Body   Expr
+		node
+	}
+
+	// X, a boolean guard
+	// Pattern <- X, a pattern guard
+	// Just x <- lookup k m
+	Guard struct {
+		Pattern Pattern // nil in a boolean guard
+		X       Expr
+		node
+	}
+
+	// X.Sel
+	// p.id, a field of a record
+	// Ref.run, a name qualified by its module or seal
+	SelectorExpr struct {
+		X         Expr
+		Sel       *Name
+		Qualified bool // X is a module, seal or enum, not a record
+		expr
+	}
+
+	// Con { Fields[0], Fields[1], ... }
+	// Person.New { id = 0, name = "Tom" }
+	// Person.New { id, name }, with punned fields
+	RecordExpr struct {
+		Con    Expr // *Name or *SelectorExpr
+		Fields []*FieldValue
+		expr
+	}
+
+	// X { Fields[0], Fields[1], ... }
+	// tom { name = "Tim" }
+	UpdateExpr struct {
+		X      Expr
+		Fields []*FieldValue
+		expr
+	}
+
+	// Name = Value
+	// Name, which stands for `Name = Name`
+	FieldValue struct {
+		Name  *Name
+		Value Expr // nil means punned
+		node
+	}
+
 	// Placeholder for an expression that failed to parse
 	// correctly and where we can't provide a better node.
 	BadExpr struct {
@@ -48,6 +207,18 @@ type (
 		expr
 	}
 
+	// "Hello, world!"
+	String struct {
+		Value string
+		expr
+	}
+
+	// 'a'
+	Rune struct {
+		Value rune
+		expr
+	}
+
 	// Name Type
 	//      Type
 	Field struct {
@@ -64,30 +235,12 @@ type expr struct {
 
 func (*expr) aExpr() {}
 
-// Patterns
-func (field *Field) Unify(expr Expr) map[*Name]Expr {
-	vs := map[*Name]Expr{}
-	vs[field.Name] = expr
-	return vs
-}
-
-func (name *Name) Unify(expr Expr) map[*Name]Expr {
-	vs := map[*Name]Expr{}
-	vs[name] = expr
-	return vs
-}
-
-func (callExpr *CallExpr) Unify(expr Expr) map[*Name]Expr {
-	vs := map[*Name]Expr{}
-	return vs
-}
-
-func (intExpr *Integer) Unify(expr Expr) map[*Name]Expr {
-	return map[*Name]Expr{}
-}
-
-func (floatExpr *Float) Unify(expr Expr) map[*Name]Expr {
-	return map[*Name]Expr{}
+// IsConName reports whether name is the name of a constructor, a type
+// or a module, which starts with an upper case letter, or with ':' for
+// an operator.
+func IsConName(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r) || r == ':'
 }
 
 // Format print expressions
@@ -110,3 +263,108 @@ func (intExpr *Integer) String() string {
 func (floatExpr *Float) String() string {
 	return fmt.Sprintf("%f", floatExpr.Value)
 }
+
+func (strExpr *String) String() string {
+	return strconv.Quote(strExpr.Value)
+}
+
+func (runeExpr *Rune) String() string {
+	return strconv.QuoteRune(runeExpr.Value)
+}
+
+func (infixExpr *InfixExpr) String() string {
+	s := fmt.Sprintf("%v", infixExpr.Exprs[0])
+	for i, op := range infixExpr.Ops {
+		s += fmt.Sprintf(" %s %v", op, infixExpr.Exprs[i+1])
+	}
+	return fmt.Sprintf("{%s}", s)
+}
+
+func (negExpr *NegExpr) String() string {
+	return fmt.Sprintf("-%v", negExpr.X)
+}
+
+func (section *SectionExpr) String() string {
+	if section.Left == nil {
+		return fmt.Sprintf("(%s %v)", section.Op, section.Right)
+	}
+	return fmt.Sprintf("(%v %s)", section.Left, section.Op)
+}
+
+func (lambda *LambdaExpr) String() string {
+	return fmt.Sprintf("(\\%v -> %v)", lambda.Params, lambda.Body)
+}
+
+func (let *LetExpr) String() string {
+	return fmt.Sprintf("(let %d decls in %v)", len(let.Decls), let.Body)
+}
+
+func (where *WhereExpr) String() string {
+	return fmt.Sprintf("(%v where %d decls)", where.Body, len(where.Decls))
+}
+
+func (ifExpr *IfExpr) String() string {
+	return fmt.Sprintf("(if %v then %v else %v)", ifExpr.Cond, ifExpr.Then, ifExpr.Else)
+}
+
+func (guarded *GuardedExpr) String() string {
+	alts := make([]string, len(guarded.Alts))
+	for i, alt := range guarded.Alts {
+		guards := make([]string, len(alt.Guards))
+		for j, guard := range alt.Guards {
+			guards[j] = guard.String()
+		}
+		alts[i] = fmt.Sprintf("| %s = %v", strings.Join(guards, ", "), alt.Body)
+	}
+	return fmt.Sprintf("(%s)", strings.Join(alts, " "))
+}
+
+func (guard *Guard) String() string {
+	if guard.Pattern == nil {
+		return fmt.Sprint(guard.X)
+	}
+	return fmt.Sprintf("%v <- %v", guard.Pattern, guard.X)
+}
+
+func (selector *SelectorExpr) String() string {
+	return fmt.Sprintf("%v.%s", selector.X, selector.Sel)
+}
+
+func (record *RecordExpr) String() string {
+	return fmt.Sprintf("%v %s", record.Con, fieldValues(record.Fields))
+}
+
+func (update *UpdateExpr) String() string {
+	return fmt.Sprintf("(%v %s)", update.X, fieldValues(update.Fields))
+}
+
+func (field *FieldValue) String() string {
+	if field.Value == nil {
+		return field.Name.Value
+	}
+	return fmt.Sprintf("%s = %v", field.Name, field.Value)
+}
+
+func fieldValues(fields []*FieldValue) string {
+	s := make([]string, len(fields))
+	for i, field := range fields {
+		s[i] = field.String()
+	}
+	return fmt.Sprintf("{%s}", strings.Join(s, ", "))
+}
+
+func (do *DoExpr) String() string {
+	stmts := make([]string, len(do.Stmts))
+	for i, stmt := range do.Stmts {
+		stmts[i] = fmt.Sprint(stmt)
+	}
+	return fmt.Sprintf("(do {%s})", strings.Join(stmts, "; "))
+}
+
+func (caseExpr *CaseExpr) String() string {
+	alts := ""
+	for _, alt := range caseExpr.Alts {
+		alts += fmt.Sprintf("; %v -> %v", alt.Pattern, alt.Body)
+	}
+	return fmt.Sprintf("(case %v of {%s})", caseExpr.X, strings.TrimPrefix(alts, "; "))
+}
@@ -0,0 +1,184 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// Patterns are not expressions: they only appear as the parameters
+	// of equations and lambdas, and in case alternatives.
+	Pattern interface {
+		Node
+		// Bindings returns the variables bound by the pattern,
+		// from left to right.
+		Bindings() []*Name
+		aPattern() // Just for constraint... golang hack!
+	}
+
+	// x
+	VarPattern struct {
+		Name *Name
+		pattern
+	}
+
+	// _
+	WildcardPattern struct {
+		pattern
+	}
+
+	// 0
+	// "abc"
+	// 'a'
+	LitPattern struct {
+		Value Expr // *Integer, *Float, *String or *Rune
+		pattern
+	}
+
+	// Con Args[0] Args[1] ...
+	// Nil
+	// Just x
+	// x :: xs, with Infix set
+	//
+	// Lists, tuples and the unit are sugar for constructor patterns:
+	// [a, b] is a :: b :: Nil, (a, b) is Tuple2 a b and () is Unit.
+	ConPattern struct {
+		Con   *Name
+		Args  []Pattern
+		Infix bool // written between its two arguments
+		pattern
+	}
+
+	// (Pattern : Type)
+	// (x : Int), a named parameter
+	TypedPattern struct {
+		Pattern Pattern
+		Type    Type
+		pattern
+	}
+
+	// Name@Pattern
+	// xs@(x :: _)
+	AsPattern struct {
+		Name    *Name
+		Pattern Pattern
+		pattern
+	}
+
+	// Con { Fields[0], Fields[1], ... }
+	// New { id = i, name }
+	// { id = i }
+	RecordPattern struct {
+		Con    *Name // nil means any constructor with these fields
+		Fields []*FieldPattern
+		pattern
+	}
+
+	// Name = Pattern
+	// Name, which binds the field to a variable of the same name
+	FieldPattern struct {
+		Name    *Name
+		Pattern Pattern // nil means punned
+		node
+	}
+
+	// Patterns[0] Ops[0] Patterns[1] Ops[1] ... Patterns[n]
+	// x :: y :: xs
+	//
+	// Like InfixExpr, a chain of infix constructors before their
+	// fixities are known. Resolving the operators turns it into
+	// nested ConPatterns.
+	InfixPattern struct {
+		Patterns []Pattern
+		Ops      []*Name
+		pattern
+	}
+)
+
+type pattern struct{ node }
+
+func (*pattern) aPattern() {}
+
+// Bindings
+func (p *VarPattern) Bindings() []*Name      { return []*Name{p.Name} }
+func (p *WildcardPattern) Bindings() []*Name { return nil }
+func (p *LitPattern) Bindings() []*Name      { return nil }
+func (p *ConPattern) Bindings() []*Name      { return bindingsOf(p.Args) }
+func (p *TypedPattern) Bindings() []*Name    { return p.Pattern.Bindings() }
+func (p *AsPattern) Bindings() []*Name       { return append([]*Name{p.Name}, p.Pattern.Bindings()...) }
+func (p *InfixPattern) Bindings() []*Name    { return bindingsOf(p.Patterns) }
+
+func (p *RecordPattern) Bindings() []*Name {
+	names := []*Name{}
+	for _, field := range p.Fields {
+		if field.Pattern == nil {
+			names = append(names, field.Name)
+		} else {
+			names = append(names, field.Pattern.Bindings()...)
+		}
+	}
+	return names
+}
+
+func bindingsOf(patterns []Pattern) []*Name {
+	names := []*Name{}
+	for _, p := range patterns {
+		names = append(names, p.Bindings()...)
+	}
+	return names
+}
+
+// Format patterns
+func (p *VarPattern) String() string      { return p.Name.Value }
+func (p *WildcardPattern) String() string { return "_" }
+func (p *LitPattern) String() string      { return fmt.Sprint(p.Value) }
+
+func (p *ConPattern) String() string {
+	if p.Infix && len(p.Args) == 2 {
+		return fmt.Sprintf("(%v %s %v)", p.Args[0], p.Con, p.Args[1])
+	}
+	if len(p.Args) == 0 {
+		return p.Con.Value
+	}
+	return fmt.Sprintf("(%s %s)", p.Con, joinPatterns(p.Args, " "))
+}
+
+func (p *TypedPattern) String() string {
+	return fmt.Sprintf("(%v : %v)", p.Pattern, p.Type)
+}
+
+func (p *AsPattern) String() string {
+	return fmt.Sprintf("%s@%v", p.Name, p.Pattern)
+}
+
+func (p *RecordPattern) String() string {
+	fields := make([]string, len(p.Fields))
+	for i, field := range p.Fields {
+		if field.Pattern == nil {
+			fields[i] = field.Name.Value
+		} else {
+			fields[i] = fmt.Sprintf("%s = %v", field.Name, field.Pattern)
+		}
+	}
+	s := fmt.Sprintf("{%s}", strings.Join(fields, ", "))
+	if p.Con != nil {
+		s = p.Con.Value + " " + s
+	}
+	return s
+}
+
+func (p *InfixPattern) String() string {
+	s := fmt.Sprint(p.Patterns[0])
+	for i, op := range p.Ops {
+		s += fmt.Sprintf(" %s %v", op, p.Patterns[i+1])
+	}
+	return fmt.Sprintf("{%s}", s)
+}
+
+func joinPatterns(patterns []Pattern, sep string) string {
+	s := make([]string, len(patterns))
+	for i, p := range patterns {
+		s[i] = fmt.Sprint(p)
+	}
+	return strings.Join(s, sep)
+}
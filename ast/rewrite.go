@@ -0,0 +1,532 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Rewrite returns the AST node with every node of it replaced by
+// what f returns for it, f being called in depth-first order, on the
+// children of a node before the node itself, and on the children in
+// source order, as Walk visits them. f may return the node it is
+// given to keep it.
+//
+// The nodes of the AST are never modified: a node whose children are
+// replaced is copied, and the copy is given to f. The AST returned
+// shares the subtrees which are left unchanged. The node returned by
+// f must be of the kind of the node it replaces, an Expr for an Expr,
+// a Pattern for a Pattern and so on, or Rewrite panics.
+func Rewrite(node Node, f func(Node) Node) Node {
+	return (&rewriter{f}).rewrite(node)
+}
+
+type rewriter struct {
+	f func(Node) Node
+}
+
+func (r *rewriter) rewrite(node Node) Node {
+	switch n := node.(type) {
+	// Files and declarations
+	case *File:
+		c := *n
+		module := rewriteNode(r, &c.Module)
+		if module {
+			c.PkgName = c.Module.Name
+		}
+		if changed(module, rewriteList(r, &c.DeclList)) {
+			node = &c
+		}
+
+	case *ImportDecl:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Alias),
+			rewriteList(r, &c.Items),
+		) {
+			node = &c
+		}
+
+	case *ModuleDecl:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Name),
+			rewriteList(r, &c.ExportList),
+		) {
+			node = &c
+		}
+
+	case *ExportSpec:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Name),
+			rewriteList(r, &c.Members),
+		) {
+			node = &c
+		}
+
+	case *TypeDecl:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Name),
+			rewriteNode(r, &c.Type),
+		) {
+			node = &c
+		}
+
+	case *FuncDecl:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Name),
+			rewriteNode(r, &c.Type),
+			rewriteList(r, &c.Clauses),
+		) {
+			node = &c
+		}
+
+	case *Clause:
+		c := *n
+		if changed(
+			rewriteList(r, &c.Params),
+			rewriteNode(r, &c.Body),
+		) {
+			node = &c
+		}
+
+	case *EnumDecl:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Name),
+			rewriteValues(r, &c.Params),
+			rewriteValues(r, &c.Cons),
+		) {
+			node = &c
+		}
+
+	case *SealDecl:
+		c := *n
+		if changed(
+			rewriteValues(r, &c.Context),
+			rewriteNode(r, &c.Name),
+			rewriteValues(r, &c.Params),
+			rewriteValues(r, &c.Fields),
+			rewriteList(r, &c.Defaults),
+		) {
+			node = &c
+		}
+
+	case *ImplDecl:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Name),
+			rewriteValues(r, &c.Context),
+			rewriteNode(r, &c.Seal),
+			rewriteList(r, &c.Args),
+			rewriteList(r, &c.Methods),
+			rewriteNode(r, &c.Value),
+		) {
+			node = &c
+		}
+
+	case *FixityDecl:
+		c := *n
+		if rewriteList(r, &c.Ops) {
+			node = &c
+		}
+
+	case *BadDecl:
+		// nothing to do
+
+	// Expressions
+	case *BadExpr, *Name, *Integer, *Float, *String, *Rune:
+		// nothing to do
+
+	case *CallExpr:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Fun),
+			rewriteList(r, &c.ArgList),
+		) {
+			node = &c
+		}
+
+	case *InfixExpr:
+		c := *n
+		if rewriteInfix(r, &c.Exprs, &c.Ops) {
+			node = &c
+		}
+
+	case *NegExpr:
+		c := *n
+		if rewriteNode(r, &c.X) {
+			node = &c
+		}
+
+	case *SectionExpr:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Left),
+			rewriteNode(r, &c.Op),
+			rewriteNode(r, &c.Right),
+		) {
+			node = &c
+		}
+
+	case *LambdaExpr:
+		c := *n
+		if changed(
+			rewriteList(r, &c.Params),
+			rewriteNode(r, &c.Body),
+		) {
+			node = &c
+		}
+
+	case *LetExpr:
+		c := *n
+		if changed(
+			rewriteList(r, &c.Decls),
+			rewriteNode(r, &c.Body),
+		) {
+			node = &c
+		}
+
+	case *WhereExpr:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Body),
+			rewriteList(r, &c.Decls),
+		) {
+			node = &c
+		}
+
+	case *IfExpr:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Cond),
+			rewriteNode(r, &c.Then),
+			rewriteNode(r, &c.Else),
+		) {
+			node = &c
+		}
+
+	case *CaseExpr:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.X),
+			rewriteList(r, &c.Alts),
+		) {
+			node = &c
+		}
+
+	case *CaseAlt:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Pattern),
+			rewriteNode(r, &c.Body),
+		) {
+			node = &c
+		}
+
+	case *DoExpr:
+		c := *n
+		if rewriteList(r, &c.Stmts) {
+			node = &c
+		}
+
+	case *GuardedExpr:
+		c := *n
+		if rewriteList(r, &c.Alts) {
+			node = &c
+		}
+
+	case *GuardedAlt:
+		c := *n
+		if changed(
+			rewriteList(r, &c.Guards),
+			rewriteNode(r, &c.Body),
+		) {
+			node = &c
+		}
+
+	case *Guard:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Pattern),
+			rewriteNode(r, &c.X),
+		) {
+			node = &c
+		}
+
+	case *SelectorExpr:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.X),
+			rewriteNode(r, &c.Sel),
+		) {
+			node = &c
+		}
+
+	case *RecordExpr:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Con),
+			rewriteList(r, &c.Fields),
+		) {
+			node = &c
+		}
+
+	case *UpdateExpr:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.X),
+			rewriteList(r, &c.Fields),
+		) {
+			node = &c
+		}
+
+	case *FieldValue:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Name),
+			rewriteNode(r, &c.Value),
+		) {
+			node = &c
+		}
+
+	case *Field:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Name),
+			rewriteNode(r, &c.Type),
+		) {
+			node = &c
+		}
+
+	// Types
+	case *FuncType:
+		c := *n
+		if changed(
+			rewriteValues(r, &c.Context),
+			rewriteList(r, &c.Types),
+		) {
+			node = &c
+		}
+
+	case *RecordType:
+		c := *n
+		if rewriteValues(r, &c.Fields) {
+			node = &c
+		}
+
+	// Statements
+	case *BindStmt:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Pattern),
+			rewriteNode(r, &c.X),
+		) {
+			node = &c
+		}
+
+	case *LetStmt:
+		c := *n
+		if rewriteList(r, &c.Decls) {
+			node = &c
+		}
+
+	case *ExprStmt:
+		c := *n
+		if rewriteNode(r, &c.X) {
+			node = &c
+		}
+
+	// Patterns
+	case *VarPattern:
+		c := *n
+		if rewriteNode(r, &c.Name) {
+			node = &c
+		}
+
+	case *WildcardPattern:
+		// nothing to do
+
+	case *LitPattern:
+		c := *n
+		if rewriteNode(r, &c.Value) {
+			node = &c
+		}
+
+	case *ConPattern:
+		c := *n
+		if c.Infix && len(c.Args) == 2 {
+			args := []*Name{c.Con}
+			if rewriteInfix(r, &c.Args, &args) {
+				c.Con = args[0]
+				node = &c
+			}
+			break
+		}
+		if changed(
+			rewriteNode(r, &c.Con),
+			rewriteList(r, &c.Args),
+		) {
+			node = &c
+		}
+
+	case *TypedPattern:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Pattern),
+			rewriteNode(r, &c.Type),
+		) {
+			node = &c
+		}
+
+	case *AsPattern:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Name),
+			rewriteNode(r, &c.Pattern),
+		) {
+			node = &c
+		}
+
+	case *RecordPattern:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Con),
+			rewriteList(r, &c.Fields),
+		) {
+			node = &c
+		}
+
+	case *FieldPattern:
+		c := *n
+		if changed(
+			rewriteNode(r, &c.Name),
+			rewriteNode(r, &c.Pattern),
+		) {
+			node = &c
+		}
+
+	case *InfixPattern:
+		c := *n
+		if rewriteInfix(r, &c.Patterns, &c.Ops) {
+			node = &c
+		}
+
+	default:
+		panic(fmt.Sprintf("ast.Rewrite: unexpected node type %T", n))
+	}
+
+	return r.f(node)
+}
+
+// changed reports whether any of the children was replaced. Taking
+// them all as arguments rewrites every child before the test.
+func changed(children ...bool) bool {
+	for _, c := range children {
+		if c {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteNode rewrites the child *n, unless it is nil, and reports
+// whether it was replaced.
+func rewriteNode[N Node](r *rewriter, n *N) bool {
+	if isNil(*n) {
+		return false
+	}
+	result := r.rewrite(*n)
+	m, ok := result.(N)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T cannot replace %T", result, *n))
+	}
+	if Node(m) == Node(*n) {
+		return false
+	}
+	*n = m
+	return true
+}
+
+// rewriteList rewrites the children in *list, and reports whether
+// any was replaced, in which case *list is a new slice.
+func rewriteList[N Node](r *rewriter, list *[]N) bool {
+	var out []N // nil until a child is replaced
+	for i, n := range *list {
+		if rewriteNode(r, &n) {
+			if out == nil {
+				out = append([]N(nil), *list...)
+			}
+			out[i] = n
+		}
+	}
+	if out == nil {
+		return false
+	}
+	*list = out
+	return true
+}
+
+// rewriteInfix rewrites the operands of an infix expression or pattern
+// and its operators in source order, the operators coming between the
+// operands, like rewriteList.
+func rewriteInfix[N Node](r *rewriter, operands *[]N, ops *[]*Name) bool {
+	var outOperands []N // nil until an operand is replaced
+	var outOps []*Name  // nil until an operator is replaced
+	for i, x := range *operands {
+		if i > 0 {
+			if op := (*ops)[i-1]; rewriteNode(r, &op) {
+				if outOps == nil {
+					outOps = append([]*Name(nil), *ops...)
+				}
+				outOps[i-1] = op
+			}
+		}
+		if rewriteNode(r, &x) {
+			if outOperands == nil {
+				outOperands = append([]N(nil), *operands...)
+			}
+			outOperands[i] = x
+		}
+	}
+	if outOperands != nil {
+		*operands = outOperands
+	}
+	if outOps != nil {
+		*ops = outOps
+	}
+	return outOperands != nil || outOps != nil
+}
+
+// rewriteValues rewrites the children held by value in *list, such
+// as the Fields of a context, like rewriteList.
+func rewriteValues[T any, PT interface {
+	*T
+	Node
+}](r *rewriter, list *[]T) bool {
+	var out []T // nil until a child is replaced
+	for i := range *list {
+		n := PT(&(*list)[i])
+		if rewriteNode(r, &n) {
+			if out == nil {
+				out = append([]T(nil), *list...)
+			}
+			out[i] = *n
+		}
+	}
+	if out == nil {
+		return false
+	}
+	*list = out
+	return true
+}
+
+func isNil(n Node) bool {
+	if n == nil {
+		return true
+	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
@@ -0,0 +1,42 @@
+package ast
+
+import "fmt"
+
+type (
+	// The statements of a do block
+	Stmt interface {
+		Node
+		aStmt() // Just for constraint... golang hack!
+	}
+
+	// Pattern <- X
+	// ref <- Ref.new empty
+	BindStmt struct {
+		Pattern Pattern
+		X       Expr
+		stmt
+	}
+
+	// let { Decls }
+	// let y = x + 1
+	LetStmt struct {
+		Decls []Decl // TypeDecls and FuncDecls
+		stmt
+	}
+
+	// X
+	// Ref.get ref
+	ExprStmt struct {
+		X Expr
+		stmt
+	}
+)
+
+type stmt struct{ node }
+
+func (*stmt) aStmt() {}
+
+// Format statements
+func (s *BindStmt) String() string { return fmt.Sprintf("%v <- %v", s.Pattern, s.X) }
+func (s *LetStmt) String() string  { return fmt.Sprintf("let %d decls", len(s.Decls)) }
+func (s *ExprStmt) String() string { return fmt.Sprint(s.X) }
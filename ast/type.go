@@ -1,18 +1,37 @@
 package ast
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type Type interface {
 	Expr
 }
 
 type (
+	// Context => Types[0] -> Types[1] -> ... -> Types[n]
+	// (a b : Type) => (a -> b) -> List a -> List b
+	// Show a => a, with no arrow
+	//
+	// The context holds binders such as `(a : Type)` and `a`, which
+	// have a Name, and constraints such as `Eq a`, which do not.
+	// Types holds the parameters then the result, and is kept flat,
+	// see FlattenFuncType.
 	FuncType struct {
 		Context []Field
 		Types   []Type
 		atype
 		expr
 	}
+
+	// { Fields[0], Fields[1], ... }
+	// { id : Int, name : String }
+	RecordType struct {
+		Fields []Field
+		atype
+		expr
+	}
 )
 
 type atype struct{}
@@ -25,6 +44,65 @@ func (*CallExpr) aType() {}
 // 	return fmt.Sprintf("%v", t.expr)
 // }
 
+// FlattenFuncType returns the canonical form of a type, in which the
+// function type right of the last arrow is merged into its parent:
+// `a -> (b -> c)` is the FuncType of a, b and c. Function types with
+// a context of their own are kept apart, and the parameters are
+// flattened in turn. Types other than functions are returned as is.
+func FlattenFuncType(t Type) Type {
+	f, ok := t.(*FuncType)
+	if !ok || len(f.Types) == 0 {
+		return t
+	}
+	flat := &FuncType{Context: f.Context, expr: f.expr}
+	for _, param := range f.Types[:len(f.Types)-1] {
+		flat.Types = append(flat.Types, FlattenFuncType(param))
+	}
+	result := FlattenFuncType(f.Types[len(f.Types)-1])
+	if r, ok := result.(*FuncType); ok && len(r.Context) == 0 {
+		flat.Types = append(flat.Types, r.Types...)
+	} else {
+		flat.Types = append(flat.Types, result)
+	}
+	return flat
+}
+
+// UncurryType splits the canonical form of a type into its context,
+// its parameters and its result: `Eq a => a -> a -> Bool` into
+// [Eq a], [a a] and Bool. A type other than a function has neither
+// context nor parameters, and is its own result.
+func UncurryType(t Type) (context []Field, params []Type, result Type) {
+	f, ok := FlattenFuncType(t).(*FuncType)
+	if !ok || len(f.Types) == 0 {
+		return nil, nil, t
+	}
+	n := len(f.Types) - 1
+	return f.Context, f.Types[:n:n], f.Types[n]
+}
+
 func (t *FuncType) String() string {
-	return fmt.Sprintf("(-> %s)", t.Types)
+	if len(t.Context) == 0 {
+		return fmt.Sprintf("(-> %s)", t.Types)
+	}
+	// (a : Type, Eq a => -> [a a])
+	context := make([]string, len(t.Context))
+	for i, field := range t.Context {
+		switch {
+		case field.Name == nil:
+			context[i] = fmt.Sprint(field.Type)
+		case field.Type == nil:
+			context[i] = field.Name.Value
+		default:
+			context[i] = fmt.Sprintf("%s : %v", field.Name, field.Type)
+		}
+	}
+	return fmt.Sprintf("(%s => -> %s)", strings.Join(context, ", "), t.Types)
+}
+
+func (t *RecordType) String() string {
+	fields := make([]string, len(t.Fields))
+	for i, field := range t.Fields {
+		fields[i] = fmt.Sprintf("%s : %s", field.Name, field.Type)
+	}
+	return fmt.Sprintf("{%s}", strings.Join(fields, ", "))
 }
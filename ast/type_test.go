@@ -0,0 +1,58 @@
+package ast
+
+import (
+	"fmt"
+	"testing"
+)
+
+func typeOf(name string) Type {
+	return &CallExpr{Fun: &Name{Value: name}}
+}
+
+func TestFlattenFuncType(t *testing.T) {
+	a, b, c := typeOf("a"), typeOf("b"), typeOf("c")
+	eq := Field{Type: &CallExpr{Fun: &Name{Value: "Eq"}, ArgList: []Expr{a}}}
+	cases := []struct {
+		t     Type
+		want  string
+		arity int // of the flat type
+	}{
+		{a, "a", 0},
+		// a -> (b -> c)
+		{&FuncType{Types: []Type{a, &FuncType{Types: []Type{b, c}}}}, "(-> [a b c])", 2},
+		// (a -> (b -> c)) -> c
+		{&FuncType{Types: []Type{&FuncType{Types: []Type{a, &FuncType{Types: []Type{b, c}}}}, c}}, "(-> [(-> [a b c]) c])", 1},
+		// a -> (Eq a => b -> c)
+		{&FuncType{Types: []Type{a, &FuncType{Context: []Field{eq}, Types: []Type{b, c}}}}, "(-> [a ((Eq [a]) => -> [b c])])", 1},
+	}
+	for _, c := range cases {
+		flat := FlattenFuncType(c.t)
+		if got := fmt.Sprint(flat); got != c.want {
+			t.Errorf("Expected %s, found %s", c.want, got)
+		}
+		arity := 0
+		if f, ok := flat.(*FuncType); ok {
+			arity = len(f.Types) - 1
+		}
+		if arity != c.arity {
+			t.Errorf("Expected %s to take %d parameters, found %d", c.want, c.arity, arity)
+		}
+	}
+}
+
+func TestUncurryType(t *testing.T) {
+	a, b := typeOf("a"), typeOf("b")
+	eq := Field{Type: &CallExpr{Fun: &Name{Value: "Eq"}, ArgList: []Expr{a}}}
+	// Eq a => a -> (b -> a)
+	f := &FuncType{Context: []Field{eq}, Types: []Type{a, &FuncType{Types: []Type{b, a}}}}
+	if got, want := fmt.Sprint(f), "((Eq [a]) => -> [a (-> [b a])])"; got != want {
+		t.Errorf("Expected the type to be printed as it is, %s, found %s", want, got)
+	}
+	context, params, result := UncurryType(f)
+	if len(context) != 1 || fmt.Sprint(params) != "[a b]" || result != a {
+		t.Errorf("Expected [Eq a], [a b] and a, found %v, %v and %v", context, params, result)
+	}
+	if context, params, result := UncurryType(b); context != nil || params != nil || result != b {
+		t.Errorf("Expected b to be its own result, found %v, %v and %v", context, params, result)
+	}
+}
@@ -0,0 +1,291 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order: It starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor
+// w for each of the non-nil children of node, in source order,
+// followed by a call of w.Visit(nil).
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	// Files and declarations
+	case *File:
+		if n.Module != nil {
+			Walk(v, n.Module)
+		}
+		walkList(v, n.DeclList)
+
+	case *ImportDecl:
+		if n.Alias != nil {
+			Walk(v, n.Alias)
+		}
+		walkList(v, n.Items)
+
+	case *ModuleDecl:
+		Walk(v, n.Name)
+		walkList(v, n.ExportList)
+
+	case *ExportSpec:
+		Walk(v, n.Name)
+		walkList(v, n.Members)
+
+	case *TypeDecl:
+		Walk(v, n.Name)
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+
+	case *FuncDecl:
+		Walk(v, n.Name)
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+		walkList(v, n.Clauses)
+
+	case *Clause:
+		walkList(v, n.Params)
+		Walk(v, n.Body)
+
+	case *EnumDecl:
+		Walk(v, n.Name)
+		walkFields(v, n.Params)
+		for i := range n.Cons {
+			Walk(v, &n.Cons[i])
+		}
+
+	case *SealDecl:
+		walkFields(v, n.Context)
+		Walk(v, n.Name)
+		walkFields(v, n.Params)
+		for i := range n.Fields {
+			Walk(v, &n.Fields[i])
+		}
+		walkList(v, n.Defaults)
+
+	case *ImplDecl:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		walkFields(v, n.Context)
+		Walk(v, n.Seal)
+		walkList(v, n.Args)
+		walkList(v, n.Methods)
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+
+	case *FixityDecl:
+		walkList(v, n.Ops)
+
+	case *BadDecl:
+		// nothing to do
+
+	// Expressions
+	case *BadExpr, *Name, *Integer, *Float, *String, *Rune:
+		// nothing to do
+
+	case *CallExpr:
+		Walk(v, n.Fun)
+		walkList(v, n.ArgList)
+
+	case *InfixExpr:
+		for i, x := range n.Exprs {
+			if i > 0 {
+				Walk(v, n.Ops[i-1])
+			}
+			Walk(v, x)
+		}
+
+	case *NegExpr:
+		Walk(v, n.X)
+
+	case *SectionExpr:
+		if n.Left != nil {
+			Walk(v, n.Left)
+		}
+		Walk(v, n.Op)
+		if n.Right != nil {
+			Walk(v, n.Right)
+		}
+
+	case *LambdaExpr:
+		walkList(v, n.Params)
+		Walk(v, n.Body)
+
+	case *LetExpr:
+		walkList(v, n.Decls)
+		Walk(v, n.Body)
+
+	case *WhereExpr:
+		Walk(v, n.Body)
+		walkList(v, n.Decls)
+
+	case *IfExpr:
+		Walk(v, n.Cond)
+		Walk(v, n.Then)
+		Walk(v, n.Else)
+
+	case *CaseExpr:
+		Walk(v, n.X)
+		walkList(v, n.Alts)
+
+	case *CaseAlt:
+		Walk(v, n.Pattern)
+		Walk(v, n.Body)
+
+	case *DoExpr:
+		walkList(v, n.Stmts)
+
+	case *GuardedExpr:
+		walkList(v, n.Alts)
+
+	case *GuardedAlt:
+		walkList(v, n.Guards)
+		Walk(v, n.Body)
+
+	case *Guard:
+		if n.Pattern != nil {
+			Walk(v, n.Pattern)
+		}
+		Walk(v, n.X)
+
+	case *SelectorExpr:
+		Walk(v, n.X)
+		Walk(v, n.Sel)
+
+	case *RecordExpr:
+		Walk(v, n.Con)
+		walkList(v, n.Fields)
+
+	case *UpdateExpr:
+		Walk(v, n.X)
+		walkList(v, n.Fields)
+
+	case *FieldValue:
+		Walk(v, n.Name)
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+
+	case *Field:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+
+	// Types
+	case *FuncType:
+		walkFields(v, n.Context)
+		walkList(v, n.Types)
+
+	case *RecordType:
+		walkFields(v, n.Fields)
+
+	// Statements
+	case *BindStmt:
+		Walk(v, n.Pattern)
+		Walk(v, n.X)
+
+	case *LetStmt:
+		walkList(v, n.Decls)
+
+	case *ExprStmt:
+		Walk(v, n.X)
+
+	// Patterns
+	case *VarPattern:
+		Walk(v, n.Name)
+
+	case *WildcardPattern:
+		// nothing to do
+
+	case *LitPattern:
+		Walk(v, n.Value)
+
+	case *ConPattern:
+		if n.Infix && len(n.Args) == 2 {
+			Walk(v, n.Args[0])
+			Walk(v, n.Con)
+			Walk(v, n.Args[1])
+			break
+		}
+		Walk(v, n.Con)
+		walkList(v, n.Args)
+
+	case *TypedPattern:
+		Walk(v, n.Pattern)
+		Walk(v, n.Type)
+
+	case *AsPattern:
+		Walk(v, n.Name)
+		Walk(v, n.Pattern)
+
+	case *RecordPattern:
+		if n.Con != nil {
+			Walk(v, n.Con)
+		}
+		walkList(v, n.Fields)
+
+	case *FieldPattern:
+		Walk(v, n.Name)
+		if n.Pattern != nil {
+			Walk(v, n.Pattern)
+		}
+
+	case *InfixPattern:
+		for i, p := range n.Patterns {
+			if i > 0 {
+				Walk(v, n.Ops[i-1])
+			}
+			Walk(v, p)
+		}
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkList[N Node](v Visitor, list []N) {
+	for _, node := range list {
+		Walk(v, node)
+	}
+}
+
+func walkFields(v Visitor, fields []Field) {
+	for i := range fields {
+		Walk(v, &fields[i])
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,209 @@
+package ast_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/seal-script/sealing/ast"
+	"github.com/seal-script/sealing/syntax"
+	"github.com/seal-script/sealing/utils"
+)
+
+const src = `
+module Walk (Shape(..), area)
+
+import Data.List (map)
+import qualified Data.Map as M
+
+infixl 6 <+>
+
+enum Shape a {
+    Circle { r : a }
+    Rect a a
+}
+
+seal Eq a => Sized a { size : a -> Int }
+impl Sized Shape { size s = 1 }
+
+area : Num a => Shape a -> a
+area (Circle { r }) = r * r
+area s@(Rect w h) = w * h
+
+f xs (n : Int) = case xs of
+    x :: _ | x > 0 -> -x
+    [] -> 0
+    _ -> let y = (+ 1) n in if y < 2 then y else 0
+  where
+    g = \c -> c { r = n }.r
+
+main = do
+    (a, b) <- get
+    let s = "" <+> 'c'
+    print [a, b]
+`
+
+type counter struct {
+	types map[string]int
+	depth int
+}
+
+func (c *counter) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		c.depth--
+		return nil
+	}
+	c.types[strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")]++
+	c.depth++
+	return c
+}
+
+func parse(t *testing.T) *ast.File {
+	file, err := syntax.ParseFile(utils.NewFileSet(), "walk.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestWalk(t *testing.T) {
+	c := &counter{types: make(map[string]int)}
+	ast.Walk(c, parse(t))
+	if c.depth != 0 {
+		t.Errorf("Expected a call of Visit(nil) per node, found %d missing", c.depth)
+	}
+	var types []string
+	for typ := range c.types {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	want := "AsPattern BindStmt CallExpr CaseAlt CaseExpr Clause ConPattern DoExpr EnumDecl " +
+		"ExportSpec ExprStmt Field FieldPattern FieldValue File FixityDecl FuncDecl " +
+		"FuncType Guard GuardedAlt GuardedExpr IfExpr ImplDecl ImportDecl InfixExpr InfixPattern " +
+		"Integer LambdaExpr LetExpr LetStmt ModuleDecl Name NegExpr RecordPattern RecordType " +
+		"Rune SealDecl SectionExpr SelectorExpr String TypeDecl TypedPattern UpdateExpr " +
+		"VarPattern WhereExpr WildcardPattern"
+	if got := strings.Join(types, " "); got != want {
+		t.Errorf("Expected the node types\n%s\nfound\n%s", want, got)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	file := parse(t)
+
+	// The names of the functions, without entering them
+	var funcs []string
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.File:
+			return true
+		case *ast.FuncDecl:
+			funcs = append(funcs, n.Name.Value)
+		}
+		return false
+	})
+	if got := fmt.Sprint(funcs); got != "[area f main]" {
+		t.Errorf("Expected [area f main], found %s", got)
+	}
+
+	// The names of main, in source order
+	var names []string
+	ast.Inspect(file.DeclList[len(file.DeclList)-1], func(node ast.Node) bool {
+		if n, ok := node.(*ast.Name); ok {
+			names = append(names, n.Value)
+		}
+		return true
+	})
+	want := "[main Tuple2 a b get s <+> print :: a :: b Nil]"
+	if got := fmt.Sprint(names); got != want {
+		t.Errorf("Expected %s, found %s", want, got)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	file := parse(t)
+	before := fmt.Sprint(file.DeclList)
+
+	// Rename y to z, which only changes f
+	var visited int
+	result := ast.Rewrite(file, func(node ast.Node) ast.Node {
+		visited++
+		if n, ok := node.(*ast.Name); ok && n.Value == "y" {
+			z := *n
+			z.Value = "z"
+			return &z
+		}
+		return node
+	}).(*ast.File)
+
+	if got := fmt.Sprint(file.DeclList); got != before {
+		t.Errorf("Expected the original AST to be kept, found %s", got)
+	}
+	if result == file {
+		t.Fatalf("Expected a new file")
+	}
+	fi := len(result.DeclList) - 2
+	for i := range result.DeclList {
+		if shared := result.DeclList[i] == file.DeclList[i]; shared != (i != fi) {
+			t.Errorf("Declaration %d: expected sharing to be %v", i, !shared)
+		}
+	}
+	body := fmt.Sprint(result.DeclList[fi].(*ast.FuncDecl).Clauses[0].Body)
+	if !strings.Contains(body, "let 1 decls in (if {z < 2} then z else 0)") {
+		t.Errorf("Expected y to be renamed, found %s", body)
+	}
+
+	var nodes int
+	ast.Inspect(file, func(node ast.Node) bool {
+		if node != nil {
+			nodes++
+		}
+		return true
+	})
+	if visited != nodes {
+		t.Errorf("Expected f to be called on all %d nodes, found %d calls", nodes, visited)
+	}
+
+	// The names are rewritten in the order Walk visits them, and
+	// renaming the module renames the file
+	var rewritten, inspected []string
+	result = ast.Rewrite(file, func(node ast.Node) ast.Node {
+		n, ok := node.(*ast.Name)
+		if !ok {
+			return node
+		}
+		rewritten = append(rewritten, n.Value)
+		if n.Value != "Walk" {
+			return node
+		}
+		m := *n
+		m.Value = "Walked"
+		return &m
+	}).(*ast.File)
+	ast.Inspect(file, func(node ast.Node) bool {
+		if n, ok := node.(*ast.Name); ok {
+			inspected = append(inspected, n.Value)
+		}
+		return true
+	})
+	if got, want := fmt.Sprint(rewritten), fmt.Sprint(inspected); got != want {
+		t.Errorf("Expected the names in the order\n%s\nfound\n%s", want, got)
+	}
+	if result.PkgName.Value != "Walked" || file.PkgName.Value != "Walk" {
+		t.Errorf("Expected the file to be renamed Walked, found %s", result.PkgName)
+	}
+
+	// A node of the wrong kind
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic replacing a name with a clause")
+		}
+	}()
+	ast.Rewrite(file, func(node ast.Node) ast.Node {
+		if _, ok := node.(*ast.Name); ok {
+			return &ast.Clause{}
+		}
+		return node
+	})
+}
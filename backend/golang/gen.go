@@ -2,6 +2,10 @@ package golang
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/seal-script/sealing/ast"
 	"github.com/seal-script/sealing/utils"
@@ -12,90 +16,513 @@ type Generator[Ir any] interface {
 }
 
 type GenString struct {
+	Fset *utils.FileSet // nil means no //line directives
 	TEnv map[string]ast.Type
 	FEnv map[string]*ast.FuncDecl
 }
 
 func (g *GenString) Gen(file *ast.File) (string, error) {
 	decls := file.DeclList
+	types := ""
 	for i, decl := range decls {
 		switch d := decl.(type) {
+		case *ast.EnumDecl:
+			enum, err := GenEnum(d)
+			if err != nil {
+				return "", err
+			}
+			types += "\n\n" + g.lineDirective(d) + enum
 		case *ast.TypeDecl:
 			g.TEnv[d.Name.Value] = d.Type
 		case *ast.FuncDecl:
-			decls[i].(*ast.FuncDecl).Type = g.TEnv[d.Name.Value]
+			if d.Type == nil {
+				decls[i].(*ast.FuncDecl).Type = g.TEnv[d.Name.Value]
+			}
 			g.FEnv[d.Name.Value] = d
+		case *ast.ImportDecl:
+			// Imported modules are generated on their own
+		case *ast.FixityDecl:
+			// Operators are resolved before code generation
 		default:
 			return "", fmt.Errorf("Error of generator: Gen %#v", decl)
 		}
 	}
-	ans := ""
+	ans := types
 	for _, fDecl := range g.FEnv {
 		f, err := GenFunc(fDecl)
 		if err != nil {
 			return "", err
 		}
-		ans = ans + "\n\n" + f
+		ans = ans + "\n\n" + g.lineDirective(fDecl) + f
+	}
+	return ans, nil
+}
+
+// GenEnum generates an interface for an enum, and a struct for each
+// of its constructors. The arguments of a constructor are named _0,
+// _1, ... unless it is a record, whose fields keep their names. Every
+// field of a record is read by the method get_<field> of the interface
+// and updated by with_<field>, returning an updated copy, which panic
+// on the constructors without the field. A record is built by the
+// function mk_<constructor>, taking its fields sorted by name, so that
+// their types give the type arguments of the enum. The method
+// as_<constructor> of the interface returns the struct of the
+// constructor, or nil for the others, which is how patterns match: a
+// type assertion would need the type arguments of the enum.
+func GenEnum(enum *ast.EnumDecl) (string, error) {
+	params, args := "", ""
+	for i, p := range enum.Params {
+		if i > 0 {
+			params += ", "
+			args += ", "
+		}
+		params += p.Name.Value + " any"
+		args += p.Name.Value
+	}
+	if params != "" {
+		params = "[" + params + "]"
+		args = "[" + args + "]"
+	}
+	enumType := enum.Name.Value + args
+
+	type con struct {
+		name   string
+		fields []string
+		types  map[string]string // by field
+		record bool
+	}
+	cons := make([]con, len(enum.Cons))
+	recordFields := []string{}        // of all the records, in order
+	fieldTypes := map[string]string{} // by field of a record
+	for i, c := range enum.Cons {
+		cons[i] = con{name: goName(c.Name.Value), types: map[string]string{}}
+		fields := []ast.Field{}
+		// MkPair : a -> a -> Pair a
+		_, ts, _ := ast.UncurryType(c.Type)
+		if len(ts) == 1 {
+			if r, ok := ts[0].(*ast.RecordType); ok {
+				fields, cons[i].record = r.Fields, true
+			}
+		}
+		for j := 0; !cons[i].record && j < len(ts); j++ {
+			fields = append(fields, ast.Field{Name: &ast.Name{Value: fmt.Sprintf("_%d", j)}, Type: ts[j]})
+		}
+		for _, field := range fields {
+			name := field.Name.Value
+			t, err := GenType(field.Type)
+			if err != nil {
+				return "", err
+			}
+			cons[i].fields = append(cons[i].fields, name)
+			cons[i].types[name] = t
+			if !cons[i].record {
+				continue
+			}
+			if prev, ok := fieldTypes[name]; !ok {
+				recordFields = append(recordFields, name)
+				fieldTypes[name] = t
+			} else if prev != t {
+				return "", fmt.Errorf("Error of generator: GenEnum: field %s of %s has the types %s and %s",
+					name, enum.Name, prev, t)
+			}
+		}
+	}
+
+	marker := "is" + enum.Name.Value
+	methods := marker + "()\n"
+	for _, field := range recordFields {
+		t := fieldTypes[field]
+		methods += fmt.Sprintf("get_%s() %s\nwith_%s(v %s) %s\n", field, t, field, t, enumType)
+	}
+	for _, c := range cons {
+		methods += fmt.Sprintf("as_%s() *%s%s\n", c.name, c.name, args)
+	}
+	ans := fmt.Sprintf("type %s%s interface {\n%s}\n", enum.Name.Value, params, methods)
+	for _, c := range cons {
+		body := ""
+		for _, field := range c.fields {
+			body += fmt.Sprintf("%s %s\n", field, c.types[field])
+		}
+		ans += fmt.Sprintf("\ntype %s%s struct {\n%s}\n", c.name, params, body)
+		ans += fmt.Sprintf("\nfunc (%s%s) %s() {}\n", c.name, args, marker)
+		for _, d := range cons {
+			if d.name == c.name {
+				ans += fmt.Sprintf("\nfunc (r %s%s) as_%s() *%s%s {\nreturn &r\n}\n", c.name, args, c.name, c.name, args)
+			} else {
+				ans += fmt.Sprintf("\nfunc (%s%s) as_%s() *%s%s {\nreturn nil\n}\n", c.name, args, d.name, d.name, args)
+			}
+		}
+		for _, field := range recordFields {
+			t := fieldTypes[field]
+			if _, ok := c.types[field]; !ok {
+				fail := fmt.Sprintf("panic(%q)", c.name+" has no field "+field)
+				ans += fmt.Sprintf("\nfunc (%s%s) get_%s() %s {\n%s\n}\n", c.name, args, field, t, fail)
+				ans += fmt.Sprintf("\nfunc (%s%s) with_%s(v %s) %s {\n%s\n}\n", c.name, args, field, t, enumType, fail)
+				continue
+			}
+			ans += fmt.Sprintf("\nfunc (r %s%s) get_%s() %s {\nreturn r.%s\n}\n", c.name, args, field, t, field)
+			ans += fmt.Sprintf("\nfunc (r %s%s) with_%s(v %s) %s {\nr.%s = v\nreturn r\n}\n",
+				c.name, args, field, t, enumType, field)
+		}
+		if c.record {
+			sorted := append([]string(nil), c.fields...)
+			sort.Strings(sorted)
+			ps := make([]string, len(sorted))
+			inits := make([]string, len(sorted))
+			for i, field := range sorted {
+				ps[i] = field + " " + c.types[field]
+				inits[i] = field + ": " + field
+			}
+			ans += fmt.Sprintf("\nfunc mk_%s%s(%s) %s {\nreturn %s%s{%s}\n}\n",
+				c.name, params, strings.Join(ps, ", "), enumType, c.name, args, strings.Join(inits, ", "))
+		}
 	}
 	return ans, nil
 }
 
 func GenFunc(fDecl *ast.FuncDecl) (string, error) {
-	ans := fmt.Sprintf(`func %s`, fDecl.Name.Value)
+	ans := fmt.Sprintf(`func %s`, goName(fDecl.Name.Value))
 	utils.Todo()
-	ts := fDecl.Type.(*ast.FuncType).Types
-	ps := fDecl.Params
-	params := ""
-	for i, p := range ps {
-		e, err := GenExpr(p.(ast.Expr))
+	if fDecl.Type == nil {
+		return "", fmt.Errorf("Error of generator: GenFunc: %s has no signature", fDecl.Name)
+	}
+	clauses := fDecl.Clauses
+	// The parameters the type has beyond those of the clauses are
+	// those of the function returned
+	_, ts, result := ast.UncurryType(fDecl.Type)
+	arity := len(clauses[0].Params)
+	if arity > len(ts) {
+		return "", fmt.Errorf("Error of generator: GenFunc: %s has %d parameters, its type only %d", fDecl.Name, arity, len(ts))
+	}
+	if arity < len(ts) {
+		result = &ast.FuncType{Types: append(ts[arity:], result)}
+	}
+	resultType, err := GenType(result)
+	if err != nil {
+		return "", err
+	}
+	// A single unguarded equation of variables takes them as its
+	// parameters, the clauses of other functions match the arguments
+	// _0, _1, ...
+	ps := make([]string, len(clauses[0].Params))
+	_, guarded := clauses[0].Body.(*ast.GuardedExpr)
+	simple := len(clauses) == 1 && !guarded
+	for i, p := range clauses[0].Params {
+		e, err := GenPattern(p)
 		if err != nil {
-			return "", err
+			simple = false
+		}
+		ps[i] = e
+	}
+	if !simple {
+		for i := range ps {
+			ps[i] = fmt.Sprintf("_%d", i)
 		}
+	}
+	params := ""
+	for i, p := range ps {
 		t, err := GenType(ts[i])
 		if err != nil {
-			return "", nil
+			return "", err
 		}
-		pair := fmt.Sprintf("%s %s", e, t)
+		pair := fmt.Sprintf("%s %s", p, t)
 		if params == "" {
 			params = pair
 		} else {
 			params += ", " + pair
 		}
 	}
-	ans += fmt.Sprintf("(%s)", params)
+	ans += fmt.Sprintf("(%s) %s", params, resultType)
 	ans += " {\n"
-	body, err := GenExpr(fDecl.Body)
-	if err != nil {
-		return "", err
+	if simple {
+		body, err := GenExpr(clauses[0].Body)
+		if err != nil {
+			return "", err
+		}
+		ans += "return " + body
+		ans += "\n}"
+		return ans, nil
+	}
+	exhaustive := false
+	for _, clause := range clauses {
+		c, always, err := GenClause(clause, ps)
+		if err != nil {
+			return "", err
+		}
+		ans += c
+		if always {
+			exhaustive = true
+			break
+		}
+	}
+	if !exhaustive {
+		ans += fmt.Sprintf("panic(%q)\n", "Non-exhaustive patterns in function "+fDecl.Name.Value)
 	}
-	ans += "return " + body
-	ans += "\n}"
+	ans += "}"
 	return ans, nil
 }
 
+// GenClause generates a clause matching the arguments args, and
+// reports whether it always matches. A clause which does not match
+// falls through to the next one.
+func GenClause(clause *ast.Clause, args []string) (string, bool, error) {
+	conds := []string{}
+	binds := ""
+	for i, p := range clause.Params {
+		c, b, err := GenMatch(p, args[i])
+		if err != nil {
+			return "", false, err
+		}
+		conds = append(conds, c...)
+		binds += b
+	}
+	body, always, err := GenRhs(clause.Body)
+	if err != nil {
+		return "", false, err
+	}
+	block := binds + body
+	if len(conds) == 0 {
+		return "{\n" + block + "}\n", always, nil
+	}
+	return fmt.Sprintf("if %s {\n%s}\n", strings.Join(conds, " && "), block), false, nil
+}
+
+// GenRhs generates the return of the body of a clause, and reports
+// whether it always returns: it does not when no guard may hold.
+func GenRhs(body ast.Expr) (string, bool, error) {
+	guarded, ok := body.(*ast.GuardedExpr)
+	if !ok {
+		e, err := GenExpr(body)
+		if err != nil {
+			return "", false, err
+		}
+		return "return " + e + "\n", true, nil
+	}
+	ans := ""
+	for _, alt := range guarded.Alts {
+		g, always, err := GenGuards(alt.Guards, alt.Body)
+		if err != nil {
+			return "", false, err
+		}
+		ans += g
+		if always {
+			return ans, true, nil
+		}
+	}
+	return ans, false, nil
+}
+
+// GenGuards generates the return of body under guards, and reports
+// whether they always hold.
+func GenGuards(guards []*ast.Guard, body ast.Expr) (string, bool, error) {
+	if len(guards) == 0 {
+		return GenRhs(body)
+	}
+	rest, always, err := GenGuards(guards[1:], body)
+	if err != nil {
+		return "", false, err
+	}
+	guard := guards[0]
+	if guard.Pattern == nil {
+		if name, ok := guard.X.(*ast.Name); ok && (name.Value == "otherwise" || name.Value == "True") {
+			return rest, always, nil
+		}
+		cond, err := GenExpr(guard.X)
+		if err != nil {
+			return "", false, err
+		}
+		return fmt.Sprintf("if %s {\n%s}\n", cond, rest), false, nil
+	}
+	// A pattern guard matches the pattern against _g
+	x, err := GenExpr(guard.X)
+	if err != nil {
+		return "", false, err
+	}
+	conds, binds, err := GenMatch(guard.Pattern, "_g")
+	if err != nil {
+		return "", false, err
+	}
+	inner := binds + rest
+	if len(conds) > 0 {
+		inner = fmt.Sprintf("if %s {\n%s}\n", strings.Join(conds, " && "), inner)
+		always = false
+	}
+	return fmt.Sprintf("{\n_g := %s\n_ = _g\n%s}\n", x, inner), always, nil
+}
+
+// GenMatch generates the conditions under which arg matches a
+// pattern, and the bindings of its variables.
+func GenMatch(pattern ast.Pattern, arg string) ([]string, string, error) {
+	switch p := pattern.(type) {
+	case *ast.VarPattern:
+		return nil, fmt.Sprintf("%s := %s\n_ = %s\n", p.Name.Value, arg, p.Name.Value), nil
+	case *ast.WildcardPattern:
+		return nil, "", nil
+	case *ast.LitPattern:
+		lit, err := GenExpr(p.Value)
+		if err != nil {
+			return nil, "", err
+		}
+		return []string{fmt.Sprintf("%s == %s", arg, lit)}, "", nil
+	case *ast.AsPattern:
+		conds, binds, err := GenMatch(p.Pattern, arg)
+		if err != nil {
+			return nil, "", err
+		}
+		return conds, fmt.Sprintf("%s := %s\n_ = %s\n", p.Name.Value, arg, p.Name.Value) + binds, nil
+	case *ast.TypedPattern:
+		return GenMatch(p.Pattern, arg)
+	case *ast.ConPattern:
+		// x :: xs matches when arg.as_op_colon_colon() is not nil, and
+		// x its field _0
+		con := fmt.Sprintf("%s.as_%s()", arg, goName(p.Con.Value))
+		conds := []string{con + " != nil"}
+		binds := ""
+		for i, sub := range p.Args {
+			c, b, err := GenMatch(sub, fmt.Sprintf("%s._%d", con, i))
+			if err != nil {
+				return nil, "", err
+			}
+			conds = append(conds, c...)
+			binds += b
+		}
+		return conds, binds, nil
+	case *ast.RecordPattern:
+		con := fmt.Sprintf("%s.as_%s()", arg, goName(p.Con.Value))
+		conds := []string{con + " != nil"}
+		binds := ""
+		for _, field := range p.Fields {
+			var sub ast.Pattern = field.Pattern
+			if sub == nil {
+				// A punned field binds the variable of its name
+				sub = &ast.VarPattern{Name: field.Name}
+			}
+			c, b, err := GenMatch(sub, con+"."+field.Name.Value)
+			if err != nil {
+				return nil, "", err
+			}
+			conds = append(conds, c...)
+			binds += b
+		}
+		return conds, binds, nil
+	case *ast.InfixPattern:
+		// Chains are resolved by syntax.ResolveOperators, all but
+		// those of a single operator
+		if len(p.Ops) != 1 {
+			return nil, "", fmt.Errorf("Error of generator: GenMatch: Unresolved operators: %v", p)
+		}
+		return GenMatch(&ast.ConPattern{Con: p.Ops[0], Args: p.Patterns, Infix: true}, arg)
+	default:
+		return nil, "", fmt.Errorf("Error of generator: GenMatch: Unsupported pattern: %v", p)
+	}
+}
+
 func GenExpr(expr ast.Expr) (string, error) {
 	switch e := expr.(type) {
 	case *ast.CallExpr:
 		return GenFuncCall(e)
 	case *ast.Name:
-		return e.Value, nil
+		return goName(e.Value), nil
+	case *ast.InfixExpr, *ast.NegExpr:
+		// Chains are resolved by syntax.ResolveOperators, all but
+		// those of a single operator
+		call := operation(e)
+		if call == nil {
+			return "", fmt.Errorf("Error of generator: GenExpr: Unresolved operators: %v", e)
+		}
+		return GenFuncCall(call)
+	case *ast.Integer, *ast.String, *ast.Rune:
+		return fmt.Sprintf("%v", e), nil
+	case *ast.SelectorExpr:
+		x, err := GenExpr(e.X)
+		if err != nil {
+			return "", err
+		}
+		if e.Qualified {
+			// A name qualified by its module or seal
+			return x + "." + e.Sel.Value, nil
+		}
+		// p.id is p.get_id()
+		return fmt.Sprintf("%s.get_%s()", x, e.Sel.Value), nil
+	case *ast.RecordExpr:
+		// Person.New { name = "Tom", id = 0 } is mk_New(0, "Tom")
+		con := e.Con
+		if selector, ok := con.(*ast.SelectorExpr); ok {
+			con = selector.Sel
+		}
+		fields := append([]*ast.FieldValue(nil), e.Fields...)
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].Name.Value < fields[j].Name.Value })
+		args := make([]string, len(fields))
+		for i, field := range fields {
+			v, err := genFieldValue(field)
+			if err != nil {
+				return "", err
+			}
+			args[i] = v
+		}
+		return fmt.Sprintf("mk_%v(%s)", con, strings.Join(args, ", ")), nil
+	case *ast.UpdateExpr:
+		// p { name = "Tim" } is p.with_name("Tim")
+		ans, err := GenExpr(e.X)
+		if err != nil {
+			return "", err
+		}
+		for _, field := range e.Fields {
+			v, err := genFieldValue(field)
+			if err != nil {
+				return "", err
+			}
+			ans += fmt.Sprintf(".with_%s(%s)", field.Name.Value, v)
+		}
+		return ans, nil
 	default:
 		return "", fmt.Errorf("Error of generator: GenExpr: Unknown expr: %#v", e)
 	}
 }
 
+// genFieldValue generates the value of a field, which is the variable
+// of the same name when the field is punned.
+func genFieldValue(field *ast.FieldValue) (string, error) {
+	if field.Value == nil {
+		return field.Name.Value, nil
+	}
+	return GenExpr(field.Value)
+}
+
+func GenPattern(pattern ast.Pattern) (string, error) {
+	switch p := pattern.(type) {
+	case *ast.VarPattern:
+		return p.Name.Value, nil
+	case *ast.WildcardPattern:
+		return "_", nil
+	case *ast.TypedPattern:
+		return GenPattern(p.Pattern)
+	default:
+		return "", fmt.Errorf("Error of generator: GenPattern: Unsupported pattern: %v", p)
+	}
+}
+
 func GenType(tpe ast.Type) (string, error) {
 	switch t := tpe.(type) {
 	case *ast.FuncType:
-		// fmt.Println(t.Types)
-		rest, err := GenType(&ast.FuncType{
-			Context: t.Context,
-			Types:   t.Types[1:],
-		})
+		// a -> b -> c is func(a, b) c, and Show a => a is a
+		_, params, result := ast.UncurryType(t)
+		if len(params) == 0 {
+			return GenType(result)
+		}
+		ps := make([]string, len(params))
+		for i, param := range params {
+			p, err := GenType(param)
+			if err != nil {
+				return "", err
+			}
+			ps[i] = p
+		}
+		r, err := GenType(result)
 		if err != nil {
 			return "", err
 		}
-		return fmt.Sprintf("func(%s) %s", t.Types[0], rest), nil
+		return fmt.Sprintf("func(%s) %s", strings.Join(ps, ", "), r), nil
 	case *ast.CallExpr:
 		ans := fmt.Sprintf("%v", t.Fun)
 		param := ""
@@ -119,35 +546,175 @@ func GenType(tpe ast.Type) (string, error) {
 	}
 }
 
+// GenFuncCall generates an application. The operators of the prelude
+// which Go has are generated as Go operators, `(+) a b` as a + b and
+// `negate a` as -a, and `f x $ y` is f(x, y).
 func GenFuncCall(funcCall *ast.CallExpr) (string, error) {
-	args := ""
-	for _, arg := range funcCall.ArgList {
-		switch param := arg.(type) {
-		case *ast.CallExpr:
-			if args == "" {
-				args = fmt.Sprintf("%v", param)
-			} else {
-				args = args + ", " + fmt.Sprintf("%v", param)
-			}
-		case *ast.Name:
-			if args == "" {
-				args = param.Value
-			} else {
-				args = args + ", " + param.Value
+	if op, ok := goOperator(funcCall); ok {
+		if len(funcCall.ArgList) == 1 {
+			x, err := genOperand(funcCall.ArgList[0], unaryPrec, true)
+			if err != nil {
+				return "", err
 			}
-		case *ast.Integer:
-			args += ", " + fmt.Sprintf("%v", param)
-		default:
-			return "", fmt.Errorf(
-				"Error of generator: GenFuncCall: Unimplemented pattern matching: %v",
-				arg,
-			)
+			return op + x, nil
 		}
+		x, err := genOperand(funcCall.ArgList[0], goPrec[op], false)
+		if err != nil {
+			return "", err
+		}
+		y, err := genOperand(funcCall.ArgList[1], goPrec[op], true)
+		if err != nil {
+			return "", err
+		}
+		return x + " " + op + " " + y, nil
+	}
+	if name, ok := funcCall.Fun.(*ast.Name); ok && name.Value == "$" && len(funcCall.ArgList) == 2 {
+		f, x := funcCall.ArgList[0], funcCall.ArgList[1]
+		call := &ast.CallExpr{Fun: f, ArgList: []ast.Expr{x}}
+		if c, ok := f.(*ast.CallExpr); ok {
+			call = &ast.CallExpr{Fun: c.Fun, ArgList: append(append([]ast.Expr(nil), c.ArgList...), x)}
+		}
+		return GenFuncCall(call)
+	}
+	args := make([]string, len(funcCall.ArgList))
+	for i, arg := range funcCall.ArgList {
+		x, err := GenExpr(arg)
+		if err != nil {
+			return "", err
+		}
+		args[i] = x
 	}
 	f, err := GenExpr(funcCall.Fun)
 	if err != nil {
 		return "", err
 	}
-	ans := fmt.Sprintf("%s(%s)", f, args)
+	ans := fmt.Sprintf("%s(%s)", f, strings.Join(args, ", "))
 	return ans, nil
 }
+
+// The operators of the prelude which are Go operators
+var goOperators = map[string]string{
+	"||":  "||",
+	"&&":  "&&",
+	"==":  "==",
+	"!=":  "!=",
+	"<":   "<",
+	"<=":  "<=",
+	">":   ">",
+	">=":  ">=",
+	"+":   "+",
+	"-":   "-",
+	"*":   "*",
+	"/":   "/",
+	"div": "/",
+	"mod": "%",
+}
+
+// goOperator returns the Go operator an application is generated to,
+// if it is the application of an operator of goOperators to two
+// operands, or of negate to one.
+func goOperator(call *ast.CallExpr) (string, bool) {
+	name, ok := call.Fun.(*ast.Name)
+	if !ok {
+		return "", false
+	}
+	if name.Value == "negate" {
+		return "-", len(call.ArgList) == 1
+	}
+	op, ok := goOperators[name.Value]
+	return op, ok && len(call.ArgList) == 2
+}
+
+// operation returns the application an expression stands for, if it
+// is an application, a chain of a single operator or a negation.
+func operation(expr ast.Expr) *ast.CallExpr {
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		return e
+	case *ast.InfixExpr:
+		if len(e.Ops) == 1 {
+			return &ast.CallExpr{Fun: e.Ops[0], ArgList: e.Exprs}
+		}
+	case *ast.NegExpr:
+		return &ast.CallExpr{Fun: &ast.Name{Value: "negate"}, ArgList: []ast.Expr{e.X}}
+	}
+	return nil
+}
+
+// The precedence of the binary operators of Go, all left associative
+var goPrec = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+}
+
+// Unary operators bind tighter than binary ones
+const unaryPrec = 6
+
+// genOperand generates an operand of a Go operator of precedence
+// prec, in parentheses when it is an operation binding less tightly,
+// or as tightly on the right.
+func genOperand(operand ast.Expr, prec int, right bool) (string, error) {
+	x, err := GenExpr(operand)
+	if err != nil {
+		return "", err
+	}
+	call := operation(operand)
+	if call == nil {
+		return x, nil
+	}
+	op, ok := goOperator(call)
+	if !ok {
+		return x, nil
+	}
+	p := goPrec[op]
+	if len(call.ArgList) == 1 {
+		p = unaryPrec
+	}
+	if p < prec || p == prec && right {
+		return "(" + x + ")", nil
+	}
+	return x, nil
+}
+
+// The names of the characters of operators in Go identifiers
+var opCharNames = map[rune]string{
+	'!': "bang", '#': "hash", '$': "dollar", '%': "percent", '&': "amp",
+	'*': "star", '+': "plus", '.': "dot", '/': "slash", '<': "lt",
+	'=': "eq", '>': "gt", '?': "quest", '@': "at", '\\': "backslash",
+	'^': "caret", '|': "bar", '-': "minus", '~': "tilde", ':': "colon",
+}
+
+// goName returns the Go identifier of a name, which is the name itself
+// unless it is an operator, whose characters are spelled out: the
+// function `<+>` of the prelude is op_lt_plus_gt.
+func goName(name string) string {
+	if name == "" || !isOperator(name) {
+		return name
+	}
+	ans := "op"
+	for _, ch := range name {
+		if n, ok := opCharNames[ch]; ok {
+			ans += "_" + n
+		} else {
+			ans += fmt.Sprintf("_u%04x", ch)
+		}
+	}
+	return ans
+}
+
+func isOperator(name string) bool {
+	ch, _ := utf8.DecodeRuneInString(name)
+	return !unicode.IsLetter(ch) && !unicode.IsDigit(ch) && ch != '_'
+}
+
+// lineDirective maps the generated code of a node back to its source.
+func (g *GenString) lineDirective(n ast.Node) string {
+	if g.Fset == nil || !n.Span().Start.IsValid() {
+		return ""
+	}
+	loc := g.Fset.Location(n.Span().Start)
+	return fmt.Sprintf("//line %s:%d:%d\n", loc.FilePath, loc.Line, loc.Col)
+}
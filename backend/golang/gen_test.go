@@ -2,22 +2,28 @@ package golang
 
 import (
 	"bytes"
+	goast "go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/seal-script/sealing/ast"
 	"github.com/seal-script/sealing/syntax"
+	"github.com/seal-script/sealing/utils"
 )
 
 func TestGen(t *testing.T) {
 	data := []byte(`
-		test : Int -> Int;
+		test : Int -> Int
 		test x = add x 1
 	`)
 	var in io.Reader = bytes.NewReader(data)
 
-	p := syntax.NewParser(t, in)
-	file, err := p.ParseFile()
+	fset := utils.NewFileSet()
+	file, err := syntax.ParseFile(fset, "test.seal", in, 0)
 	if err != nil {
 		t.Error(err)
 		return
@@ -26,6 +32,7 @@ func TestGen(t *testing.T) {
 	t.Logf("%v", file)
 
 	var g GenString = GenString{
+		Fset: fset,
 		TEnv: map[string]ast.Type{},
 		FEnv: map[string]*ast.FuncDecl{},
 	}
@@ -35,3 +42,270 @@ func TestGen(t *testing.T) {
 	}
 	t.Logf(s)
 }
+
+func TestGenClauses(t *testing.T) {
+	data := []byte(`
+fact : Int -> Int
+fact 0 = 1
+fact n = mul n n
+`)
+	file, err := syntax.ParseFile(utils.NewFileSet(), "fact.seal", data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := GenString{TEnv: map[string]ast.Type{}, FEnv: map[string]*ast.FuncDecl{}}
+	s, err := g.Gen(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The clauses are tried in order on the argument
+	for _, want := range []string{
+		"func fact(_0 Int)",
+		"if _0 == 0 {\nreturn 1\n}\n{\nn := _0\n_ = n\nreturn mul(n, n)\n}\n}",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Expected %q in %s", want, s)
+		}
+	}
+}
+
+func TestGenGuards(t *testing.T) {
+	data := []byte(`
+pick : Int -> Int
+pick n | n < 0 = 0
+       | 0 <- half n, m <- twice n = m
+pick n = n
+
+fact : Int -> Int
+fact n | n < 2 = n
+       | otherwise = n * fact (n - 1)
+
+step : Int -> Int -> Int
+step k x = g 1 x - k ` + "`mod`" + ` 2 + negate k
+
+neg : Int -> Int
+neg x = -x * 2 - negate (negate x)
+
+applied : Int -> Int
+applied x = g x $ half x + 1
+`)
+	file, err := syntax.ParseFile(utils.NewFileSet(), "pick.seal", data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syntax.ResolveOperators(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	g := GenString{TEnv: map[string]ast.Type{}, FEnv: map[string]*ast.FuncDecl{}}
+	s, err := g.Gen(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// When no guard holds, the first clause falls through to the second
+	want := "{\nn := _0\n_ = n\n" +
+		"if n < 0 {\nreturn 0\n}\n" +
+		"{\n_g := half(n)\n_ = _g\nif _g == 0 {\n{\n_g := twice(n)\n_ = _g\nm := _g\n_ = m\nreturn m\n}\n}\n}\n" +
+		"}\n" +
+		"{\nn := _0\n_ = n\nreturn n\n}\n}"
+	if !strings.Contains(s, want) {
+		t.Errorf("Expected %q in %q", want, s)
+	}
+	for _, want := range []string{
+		"if n < 2 {\nreturn n\n}\nreturn n * fact(n - 1)\n}\n}",
+		"return g(1, x) - k % 2 + -k",
+		"return -(x * 2) - -(-x)",
+		"return g(x, half(x) + 1)",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Expected %q in %s", want, s)
+		}
+	}
+	checkGo(t, s, "type Int = int\n\nfunc half(Int) Int { return 0 }\nfunc twice(Int) Int { return 0 }\n"+
+		"func g(Int, Int) Int { return 0 }\n")
+
+	// A chain of a single operator needs no resolution
+	file, err = syntax.ParseFile(utils.NewFileSet(), "pick.seal", "f : Int -> Int\nf n = n * f (n - 1)", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g = GenString{TEnv: map[string]ast.Type{}, FEnv: map[string]*ast.FuncDecl{}}
+	if s, err := g.Gen(file); err != nil || !strings.Contains(s, "return n * f(n - 1)") {
+		t.Errorf("Expected n * f(n - 1), found %s, %v", s, err)
+	}
+}
+
+func TestGenConPatterns(t *testing.T) {
+	data := []byte(`
+enum List a { Nil; (::) a (List a) }
+enum Shape { Circle Int; Rect Int Int; Dot }
+enum Person { New { id : Int, name : String }; Anon }
+
+length : List Int -> Int
+length Nil = 0
+length (x :: xs) = 1 + length xs
+
+second : List Int -> Int
+second (_ :: y :: _) = y
+second _ = 0
+
+area : Shape -> Int
+area (Rect w h) | w == h = w * w
+area (Circle r) = 3 * r * r
+area s@(Rect w _) = w
+area Dot = 0
+
+idOf : Person -> Int
+idOf (New { id = 0 }) = 1
+idOf (New { id, name = n }) = id
+idOf Anon = 0
+`)
+	file, err := syntax.ParseFile(utils.NewFileSet(), "cons.seal", data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syntax.ResolveOperators(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	g := GenString{TEnv: map[string]ast.Type{}, FEnv: map[string]*ast.FuncDecl{}}
+	s, err := g.Gen(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A clause whose constructor does not match falls through to the next
+	for _, want := range []string{
+		"type List[a any] interface {\nisList()\nas_Nil() *Nil[a]\nas_op_colon_colon() *op_colon_colon[a]\n}\n",
+		"func (r Nil[a]) as_Nil() *Nil[a] {\nreturn &r\n}\n",
+		"func (Nil[a]) as_op_colon_colon() *op_colon_colon[a] {\nreturn nil\n}\n",
+		"if _0.as_Nil() != nil {\nreturn 0\n}\n" +
+			"if _0.as_op_colon_colon() != nil {\nx := _0.as_op_colon_colon()._0\n_ = x\n" +
+			"xs := _0.as_op_colon_colon()._1\n_ = xs\nreturn 1 + length(xs)\n}\n" +
+			"panic(\"Non-exhaustive patterns in function length\")\n}",
+		"if _0.as_op_colon_colon() != nil && _0.as_op_colon_colon()._1.as_op_colon_colon() != nil {\n" +
+			"y := _0.as_op_colon_colon()._1.as_op_colon_colon()._0\n_ = y\nreturn y\n}\n{\nreturn 0\n}\n}",
+		"if _0.as_Rect() != nil {\nw := _0.as_Rect()._0\n_ = w\nh := _0.as_Rect()._1\n_ = h\n" +
+			"if w == h {\nreturn w * w\n}\n}\nif _0.as_Circle() != nil {",
+		"if _0.as_Rect() != nil {\ns := _0\n_ = s\nw := _0.as_Rect()._0\n_ = w\nreturn w\n}\n",
+		"if _0.as_New() != nil && _0.as_New().id == 0 {\nreturn 1\n}\n" +
+			"if _0.as_New() != nil {\nid := _0.as_New().id\n_ = id\nn := _0.as_New().name\n_ = n\nreturn id\n}\n" +
+			"if _0.as_Anon() != nil {\nreturn 0\n}\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Expected %q in %s", want, s)
+		}
+	}
+	checkGo(t, s, "type Int = int\ntype String = string\n")
+}
+
+// checkGo type-checks generated code as a Go package, declaring first
+// in prelude what the code uses without defining it.
+func checkGo(t *testing.T, code, prelude string) {
+	t.Helper()
+	src := "package main\n\n" + prelude + code
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "gen.go", src, 0)
+	if err == nil {
+		_, err = new(types.Config).Check("main", fset, []*goast.File{file}, nil)
+	}
+	if err != nil {
+		t.Errorf("The generated code is not valid Go: %v\n%s", err, src)
+	}
+}
+
+func TestGenRecords(t *testing.T) {
+	data := []byte(`
+enum Person { New { id : Int, name : String }; Anon }
+enum Pair a { MkPair a a }
+enum Box a { MkBox { item : a } }
+
+tom : Int -> Person
+tom id = rename (Person.New { name = "Tom", id })
+
+rename : Person -> Person
+rename p = p { name = show p.id, id = 0 }
+
+box : Int -> Box Int
+box x = MkBox { item = x }
+`)
+	file, err := syntax.ParseFile(utils.NewFileSet(), "records.seal", data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := GenString{TEnv: map[string]ast.Type{}, FEnv: map[string]*ast.FuncDecl{}}
+	s, err := g.Gen(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"type Person interface {\nisPerson()\nget_id() Int\nwith_id(v Int) Person\n",
+		"type New struct {\nid Int\nname String\n}\n",
+		"func (r New) with_name(v String) Person {\nr.name = v\nreturn r\n}\n",
+		"func (Anon) get_name() String {\npanic(\"Anon has no field name\")\n}\n",
+		"func mk_New(id Int, name String) Person {\nreturn New{id: id, name: name}\n}\n",
+		"type MkPair[a any] struct {\n_0 a\n_1 a\n}\n",
+		"func mk_MkBox[a any](item a) Box[a] {\nreturn MkBox[a]{item: item}\n}\n",
+		"return rename(mk_New(id, \"Tom\"))",
+		"return p.with_name(show(p.get_id())).with_id(0)",
+		"return mk_MkBox(x)",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Expected %q in %s", want, s)
+		}
+	}
+	checkGo(t, s, "type Int = int\ntype String = string\n\nfunc show(Int) String { return \"\" }\n")
+
+	src := "enum Shape { Circle { size : Int }; Square { size : String } }"
+	file, err = syntax.ParseFile(utils.NewFileSet(), "records.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g = GenString{TEnv: map[string]ast.Type{}, FEnv: map[string]*ast.FuncDecl{}}
+	if _, err := g.Gen(file); err == nil || !strings.Contains(err.Error(), "field size of Shape has the types Int and String") {
+		t.Errorf("Expected an error about the types of size, found %v", err)
+	}
+}
+
+func TestGenFuncTypes(t *testing.T) {
+	data := []byte(`
+add : Int -> Int -> Int
+add x y = plus x y
+
+inc : Int -> Int -> Int
+inc = add 1
+
+twice : (Int -> Int) -> Int -> Int
+twice f x = f x
+
+apply : (Show a => a) -> String
+apply x = show x
+`)
+	file, err := syntax.ParseFile(utils.NewFileSet(), "types.seal", data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := GenString{TEnv: map[string]ast.Type{}, FEnv: map[string]*ast.FuncDecl{}}
+	s, err := g.Gen(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The parameters of the type beyond those of the equations are
+	// those of the function returned
+	for _, want := range []string{
+		"func add(x Int, y Int) Int {",
+		"func inc() func(Int, Int) Int {",
+		"func twice(f func(Int) Int, x Int) Int {",
+		"func apply(x a) String {",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Expected %q in %s", want, s)
+		}
+	}
+
+	file, err = syntax.ParseFile(utils.NewFileSet(), "types.seal", "f : Int -> Int\nf x y = x", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g = GenString{TEnv: map[string]ast.Type{}, FEnv: map[string]*ast.FuncDecl{}}
+	if _, err := g.Gen(file); err == nil || !strings.Contains(err.Error(), "f has 2 parameters, its type only 1") {
+		t.Errorf("Expected an error about the arity of f, found %v", err)
+	}
+}
@@ -0,0 +1,294 @@
+// Package loader resolves the imports of a SealScript program:
+// it maps module paths onto files under a search path, detects
+// import cycles, and parses and checks every module in order.
+package loader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/seal-script/sealing/ast"
+	"github.com/seal-script/sealing/syntax"
+	"github.com/seal-script/sealing/typecheck"
+	"github.com/seal-script/sealing/utils"
+)
+
+// Source files have this extension
+const Ext = ".seal"
+
+// A Module is a loaded source file
+type Module struct {
+	Path     string // module path, e.g. Data.Functor
+	Filename string
+	File     *ast.File
+	Exports  *typecheck.Exports
+	Fixities syntax.Fixities // of the operators declared in the module
+	Imports  []*Module       // in the order of the import declarations
+}
+
+// A Loader loads modules, sharing one FileSet between all of them.
+type Loader struct {
+	Fset       *utils.FileSet
+	SearchPath []string // directories holding module files, searched in order
+	Mode       syntax.Mode
+
+	checker *typecheck.Checker
+	modules map[string]*Module // loaded modules, by path
+	loading []string           // modules being loaded, for the cycle trace
+}
+
+func NewLoader(fset *utils.FileSet, searchPath []string) *Loader {
+	return &Loader{
+		Fset:       fset,
+		SearchPath: searchPath,
+		checker:    typecheck.NewChecker(fset),
+		modules:    map[string]*Module{},
+	}
+}
+
+// Resolve maps a module path onto a file: `Data.Functor` is the file
+// Data/Functor.seal in the first directory of the search path holding it.
+func (l *Loader) Resolve(path string) (string, error) {
+	rel := filepath.Join(strings.Split(path, ".")...) + Ext
+	for _, dir := range l.SearchPath {
+		filename := filepath.Join(dir, rel)
+		if info, err := os.Stat(filename); err == nil && !info.IsDir() {
+			return filename, nil
+		}
+	}
+	return "", fmt.Errorf("cannot find module %s (%s) in any of %v", path, rel, l.SearchPath)
+}
+
+// Load loads the main module in filename together with every module it
+// imports, directly or not. The modules are returned in dependency order,
+// so each one comes after all of its imports and the main module is last.
+func (l *Loader) Load(filename string) ([]*Module, error) {
+	main, err := l.load("", filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	var order []*Module
+	seen := map[*Module]bool{}
+	var visit func(mod *Module)
+	visit = func(mod *Module) {
+		if seen[mod] {
+			return
+		}
+		seen[mod] = true
+		for _, dep := range mod.Imports {
+			visit(dep)
+		}
+		order = append(order, mod)
+	}
+	visit(main)
+	return order, nil
+}
+
+// LoadModule loads the module with the given path, and its imports.
+func (l *Loader) LoadModule(path string) (*Module, error) {
+	return l.load(path, "", nil)
+}
+
+// load loads the module path, which is found in filename unless the name
+// is empty. An empty path means the main module, named by its header.
+func (l *Loader) load(path, filename string, from *ast.ImportDecl) (*Module, error) {
+	for i, loading := range l.loading {
+		if loading == path {
+			return nil, l.cycleError(l.loading[i:], path, from)
+		}
+	}
+	if mod, ok := l.modules[path]; ok && path != "" {
+		return mod, nil
+	}
+
+	if filename == "" {
+		var err error
+		if filename, err = l.Resolve(path); err != nil {
+			if from != nil {
+				return nil, typecheck.Error{Location: from.Locate(), Msg: err.Error()}
+			}
+			return nil, err
+		}
+	}
+	file, err := syntax.ParseFile(l.Fset, filename, nil, l.Mode)
+	if err != nil {
+		return nil, err
+	}
+	name := ""
+	if file.PkgName != nil {
+		name = file.PkgName.Value
+	}
+	if path == "" {
+		path = name
+	} else if name != "" && name != path {
+		return nil, typecheck.Error{
+			Location: file.PkgName.Locate(),
+			Msg:      fmt.Sprintf("%s declares module %s, expected %s", filename, name, path),
+		}
+	}
+
+	mod := &Module{Path: path, Filename: filename, File: file}
+	if path != "" {
+		l.modules[path] = mod
+	}
+	l.loading = append(l.loading, path)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	errs := typecheck.ErrorList{}
+	for _, decl := range file.DeclList {
+		imp, ok := decl.(*ast.ImportDecl)
+		if !ok {
+			continue
+		}
+		dep, err := l.load(imp.Path, "", imp)
+		if err != nil {
+			delete(l.modules, path)
+			return nil, err
+		}
+		errs = append(errs, checkImport(imp, dep)...)
+		mod.Imports = append(mod.Imports, dep)
+	}
+
+	mod.Exports, err = l.checker.Exports(file)
+	if list, ok := err.(typecheck.ErrorList); ok {
+		errs = append(errs, list...)
+	}
+	if err := errs.Err(); err != nil {
+		delete(l.modules, path)
+		return nil, err
+	}
+
+	// Operators can be resolved now that the imported fixities are known
+	imported := syntax.Fixities{}
+	for _, dep := range mod.Imports {
+		for op, fixity := range dep.Fixities {
+			if dep.Exports.Has(op) {
+				imported[op] = fixity
+			}
+		}
+	}
+	if err := syntax.ResolveOperators(file, imported); err != nil {
+		delete(l.modules, path)
+		return nil, err
+	}
+	mod.Fixities = syntax.FixitiesOf(file)
+	if file, err = syntax.DesugarDo(file, bindOf(mod)); err != nil {
+		delete(l.modules, path)
+		return nil, err
+	}
+	mod.File = file
+
+	exports := []*typecheck.Exports{}
+	for _, dep := range mod.Imports {
+		exports = append(exports, dep.Exports)
+	}
+	if err := l.checker.CheckRecords(file, exports...); err != nil {
+		delete(l.modules, path)
+		return nil, err
+	}
+	return mod, nil
+}
+
+// checkImport checks that every name listed by an import is exported.
+func checkImport(imp *ast.ImportDecl, dep *Module) typecheck.ErrorList {
+	errs := typecheck.ErrorList{}
+	errorf := func(n ast.Node, format string, args ...any) {
+		errs = append(errs, typecheck.Error{Location: n.Locate(), Msg: fmt.Sprintf(format, args...)})
+	}
+	for _, item := range imp.Items {
+		if !dep.Exports.Has(item.Name.Value) {
+			errorf(item.Name, "module %s does not export %s", dep.Path, item.Name.Value)
+			continue
+		}
+		exported := dep.Exports.Members[item.Name.Value]
+		if item.All && exported == nil {
+			errorf(item.Name, "module %s exports no constructors or methods of %s", dep.Path, item.Name.Value)
+		}
+		for _, member := range item.Members {
+			found := false
+			for _, m := range exported {
+				found = found || m == member.Value
+			}
+			if !found {
+				errorf(member, "module %s does not export %s(%s)", dep.Path, item.Name.Value, member.Value)
+			}
+		}
+	}
+	return errs
+}
+
+// bindOf returns the bind method of the sequencing seal in scope in
+// mod, which its do blocks desugar to, or nil if there is none. A seal
+// declared in the module comes first, then those imported, in the
+// order of the imports.
+func bindOf(mod *Module) ast.Expr {
+	imports := []*ast.ImportDecl{}
+	for _, decl := range mod.File.DeclList {
+		switch d := decl.(type) {
+		case *ast.SealDecl:
+			if method := syntax.BindMethod(d); method != nil {
+				return &ast.Name{Value: method.Value}
+			}
+		case *ast.ImportDecl:
+			imports = append(imports, d)
+		}
+	}
+	for i, dep := range mod.Imports {
+		imp := imports[i]
+		for _, decl := range dep.File.DeclList {
+			seal, ok := decl.(*ast.SealDecl)
+			if !ok {
+				continue
+			}
+			method := syntax.BindMethod(seal)
+			if method == nil || !dep.Exports.Has(method.Value) || !importsMember(imp, seal.Name.Value, method.Value) {
+				continue
+			}
+			bind := &ast.Name{Value: method.Value}
+			if !imp.Qualified {
+				return bind
+			}
+			qualifier := imp.Path
+			if imp.Alias != nil {
+				qualifier = imp.Alias.Value
+			}
+			return &ast.SelectorExpr{X: &ast.Name{Value: qualifier}, Sel: bind, Qualified: true}
+		}
+	}
+	return nil
+}
+
+// importsMember reports whether imp brings into scope the member of
+// the enum or seal owner, listed by its own name or with its owner.
+func importsMember(imp *ast.ImportDecl, owner, member string) bool {
+	if imp.Items == nil {
+		return true
+	}
+	listed := false
+	for _, item := range imp.Items {
+		switch item.Name.Value {
+		case member:
+			listed = true
+		case owner:
+			listed = listed || item.All
+			for _, m := range item.Members {
+				listed = listed || m.Value == member
+			}
+		}
+	}
+	return listed != imp.Hiding
+}
+
+// cycleError reports the import cycle formed by the chain of
+// modules being loaded and the import of path closing it.
+func (l *Loader) cycleError(chain []string, path string, from *ast.ImportDecl) error {
+	var b strings.Builder
+	b.WriteString("import cycle not allowed")
+	for _, mod := range chain {
+		fmt.Fprintf(&b, "\n\t%s imports", mod)
+	}
+	fmt.Fprintf(&b, "\n\t%s", path)
+	return typecheck.Error{Location: from.Locate(), Msg: b.String()}
+}
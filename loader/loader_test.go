@@ -0,0 +1,165 @@
+package loader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seal-script/sealing/ast"
+	"github.com/seal-script/sealing/utils"
+)
+
+// writeTree writes the given files, by relative path, under a new directory
+func writeTree(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"main.seal":             "module Main\nimport Data.List (map)\nimport Data.Functor\nmain = map f xs\n",
+		"lib/Data/List.seal":    "module Data.List (map)\nimport Data.Functor (fmap)\nmap = fmap\n",
+		"lib/Data/Functor.seal": "module Data.Functor (fmap)\nfmap f x = x\n",
+	})
+	l := NewLoader(utils.NewFileSet(), []string{dir, filepath.Join(dir, "lib")})
+	mods, err := l.Load(filepath.Join(dir, "main.seal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, mod := range mods {
+		paths = append(paths, mod.Path)
+	}
+	if got, want := strings.Join(paths, " "), "Data.Functor Data.List Main"; got != want {
+		t.Errorf("Expected modules in order %q, found %q", want, got)
+	}
+	if main := mods[len(mods)-1]; len(main.Imports) != 2 || main.Imports[1] != mods[0] {
+		t.Errorf("Expected Main to import the shared Data.Functor module")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			"cycle",
+			map[string]string{
+				"main.seal": "module Main\nimport A\n",
+				"A.seal":    "module A\nimport B\n",
+				"B.seal":    "module B\nimport A\n",
+			},
+			"import cycle not allowed\n\tA imports\n\tB imports\n\tA",
+		},
+		{
+			"missing",
+			map[string]string{"main.seal": "import Data.Missing\n"},
+			"cannot find module Data.Missing",
+		},
+		{
+			"not exported",
+			map[string]string{
+				"main.seal": "import A (f, g)\n",
+				"A.seal":    "module A (f)\nf = g\ng = f\n",
+			},
+			"main.seal:1:14: module A does not export g",
+		},
+		{
+			"wrong header",
+			map[string]string{
+				"main.seal": "import A\n",
+				"A.seal":    "module B\n",
+			},
+			"declares module B, expected A",
+		},
+		{
+			"no sequencing seal",
+			map[string]string{"main.seal": "x = do { tick; get }\n"},
+			"main.seal:1:5: No sequencing seal in scope for a do block",
+		},
+		{
+			"hidden sequencing seal",
+			map[string]string{
+				"main.seal":  "import Monad hiding (Monad(..))\nx = do { tick; get }\n",
+				"Monad.seal": "module Monad (Monad(..))\nseal Monad m { bind : m a -> (a -> m b) -> m b }\n",
+			},
+			"main.seal:2:5: No sequencing seal in scope for a do block",
+		},
+	}
+	for _, c := range cases {
+		dir := writeTree(t, c.files)
+		l := NewLoader(utils.NewFileSet(), []string{dir})
+		_, err := l.Load(filepath.Join(dir, "main.seal"))
+		if err == nil {
+			t.Errorf("%s: expected an error", c.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: expected an error containing %q, found %q", c.name, c.want, err)
+		}
+	}
+}
+
+func TestLoadFixities(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"main.seal": "import Ops\nx = a <+> b <+> c\n",
+		"Ops.seal":  "module Ops ((<+>))\ninfixr 5 <+>\na <+> b = a\n",
+	})
+	l := NewLoader(utils.NewFileSet(), []string{dir})
+	mods, err := l.Load(filepath.Join(dir, "main.seal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := mods[len(mods)-1]
+	body := main.File.DeclList[1].(*ast.FuncDecl).Clauses[0].Body.(*ast.CallExpr)
+	if got, want := fmt.Sprint(body), "(<+> [a (<+> [b c])])"; got != want {
+		t.Errorf("Expected %s, found %s", want, got)
+	}
+}
+
+func TestLoadDo(t *testing.T) {
+	monad := "module Control.Monad (Monad(..))\nseal Monad m { (>>=) : m a -> (a -> m b) -> m b }\n"
+	cases := []struct {
+		main, want string
+	}{
+		{
+			"import Control.Monad\nx = do { y <- do { tick; get }; put y }\n",
+			"(>>= [(>>= [tick (\\[_] -> get)]) (\\[y] -> (put [y]))])",
+		},
+		{
+			"import qualified Control.Monad as M\nx = do { tick; get }\n",
+			"(M.>>= [tick (\\[_] -> get)])",
+		},
+		{
+			"import Control.Monad\nseal Seq m { andThen : m a -> (a -> m b) -> m b }\nx = do { tick; get }\n",
+			"(andThen [tick (\\[_] -> get)])",
+		},
+	}
+	for _, c := range cases {
+		dir := writeTree(t, map[string]string{"main.seal": c.main, "Control/Monad.seal": monad})
+		l := NewLoader(utils.NewFileSet(), []string{dir})
+		mods, err := l.Load(filepath.Join(dir, "main.seal"))
+		if err != nil {
+			t.Errorf("%q: %v", c.main, err)
+			continue
+		}
+		decls := mods[len(mods)-1].File.DeclList
+		body := decls[len(decls)-1].(*ast.FuncDecl).Clauses[0].Body
+		if got := fmt.Sprint(body); got != c.want {
+			t.Errorf("Expected %s, found %s", c.want, got)
+		}
+	}
+}
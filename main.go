@@ -1,7 +1,50 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/seal-script/sealing/loader"
+	"github.com/seal-script/sealing/syntax"
+	"github.com/seal-script/sealing/utils"
+)
 
 func main() {
-	fmt.Println("Hello, SealScript!")
+	if len(os.Args) < 2 {
+		fmt.Println("Hello, SealScript!")
+		return
+	}
+
+	// Load the given files and the modules they import with one loader,
+	// looking for modules next to the files and then in the directories
+	// of SEALPATH, so that modules imported by several files are loaded once
+	var searchPath []string
+	seen := map[string]bool{}
+	for _, filename := range os.Args[1:] {
+		if dir := filepath.Dir(filename); !seen[dir] {
+			seen[dir] = true
+			searchPath = append(searchPath, dir)
+		}
+	}
+	searchPath = append(searchPath, filepath.SplitList(os.Getenv("SEALPATH"))...)
+	l := loader.NewLoader(utils.NewFileSet(), searchPath)
+	l.Mode = syntax.AllErrors
+
+	failed := false
+	for _, filename := range os.Args[1:] {
+		_, err := l.Load(filename)
+		if errs, ok := err.(utils.ErrorList); ok {
+			for _, e := range errs {
+				fmt.Fprintln(os.Stderr, e.Error())
+			}
+			failed = true
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,102 @@
+// This file implements the grouping of equations. The parser reads
+// every equation on its own, as a function of a single clause. Once
+// a block is read, the consecutive equations of each function are
+// merged into one ast.FuncDecl, whose clauses are tried in order,
+// and the signature of the function becomes its Type.
+
+package syntax
+
+import (
+	"github.com/seal-script/sealing/ast"
+	"github.com/seal-script/sealing/utils"
+)
+
+// groupBindings merges the equations of each function of a block into
+// the first one, and moves the signature of each function into it.
+// Signatures without equations are kept as forward declarations.
+func (p *Parser) groupBindings(decls []ast.Decl) []ast.Decl {
+	g := &clauseGrouper{p: p, funcs: map[string]*ast.FuncDecl{}}
+	sigs := map[string]*ast.TypeDecl{}
+	grouped := []ast.Decl{}
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if g.add(d) {
+				grouped = append(grouped, d)
+			}
+			continue
+		case *ast.TypeDecl:
+			if prev, ok := sigs[d.Name.Value]; ok {
+				p.report(errorOf(d.Name.Locate(), "Duplicate signature of %s, already declared at %d:%d", d.Name, prev.Locate().Line, prev.Locate().Col))
+			} else {
+				sigs[d.Name.Value] = d
+			}
+		}
+		grouped = append(grouped, decl)
+		g.last = nil
+	}
+
+	decls = grouped[:0]
+	for _, decl := range grouped {
+		if d, ok := decl.(*ast.TypeDecl); ok && sigs[d.Name.Value] == d {
+			if f, ok := g.funcs[d.Name.Value]; ok {
+				f.Type = d.Type
+				if f.Doc() == nil {
+					f.SetDoc(d.Doc())
+				}
+				continue
+			}
+		}
+		decls = append(decls, decl)
+	}
+	return decls
+}
+
+// groupClauses merges the equations of each function into the first one.
+func (p *Parser) groupClauses(funcs []*ast.FuncDecl) []*ast.FuncDecl {
+	g := &clauseGrouper{p: p, funcs: map[string]*ast.FuncDecl{}}
+	grouped := []*ast.FuncDecl{}
+	for _, f := range funcs {
+		if g.add(f) {
+			grouped = append(grouped, f)
+		}
+	}
+	return grouped
+}
+
+type clauseGrouper struct {
+	p     *Parser
+	funcs map[string]*ast.FuncDecl // by name
+	last  *ast.FuncDecl            // the function of the previous declaration
+}
+
+// add merges an equation into the function it belongs to, and
+// reports whether it is the first equation of its function.
+func (g *clauseGrouper) add(eq *ast.FuncDecl) bool {
+	f, ok := g.funcs[eq.Name.Value]
+	if !ok {
+		g.funcs[eq.Name.Value] = eq
+		g.last = eq
+		return true
+	}
+	if f != g.last {
+		prev := f.Clauses[len(f.Clauses)-1]
+		g.p.report(errorOf(eq.Locate(), "Clauses of %s are not adjacent, the previous one is at %d:%d", f.Name, prev.Locate().Line, prev.Locate().Col))
+	}
+	for _, clause := range eq.Clauses {
+		if arity, want := len(clause.Params), len(f.Clauses[0].Params); arity != want && !isBad(clause) && !isBad(f.Clauses[0]) {
+			g.p.report(errorOf(clause.Locate(), "Clause of %s has %d parameters, expected %d as in the first one", f.Name, arity, want))
+		}
+		f.Clauses = append(f.Clauses, clause)
+	}
+	f.SetSpan(f.Locate(), utils.Span{Start: f.Span().Start, End: eq.Span().End})
+	g.last = f
+	return false
+}
+
+// isBad reports whether a clause failed to parse, when its
+// parameters may be missing.
+func isBad(clause *ast.Clause) bool {
+	_, bad := clause.Body.(*ast.BadExpr)
+	return bad
+}
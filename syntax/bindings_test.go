@@ -0,0 +1,110 @@
+package syntax
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/seal-script/sealing/ast"
+	"github.com/seal-script/sealing/utils"
+)
+
+func TestGroupBindings(t *testing.T) {
+	src := `
+fact : Int -> Int
+fact 0 = 1
+fact n = n * fact (n - 1)
+
+Nil ++ ys = ys
+(x :: xs) ++ ys = x :: (xs ++ ys)
+
+len xs = go 0 xs where
+    go acc Nil = acc
+    go acc (_ :: xs) = go (acc + 1) xs
+
+main : IO Unit
+
+impl Eq Bool {
+    True == True = True
+    False == False = True
+    _ == _ = False
+}
+`
+	file, err := ParseFile(utils.NewFileSet(), "group.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"fact 2 clauses : (-> [Int Int])", "++ 2 clauses", "len 1 clauses", "main :", "impl"}
+	if len(file.DeclList) != len(want) {
+		t.Fatalf("Expected %d declarations, found %d", len(want), len(file.DeclList))
+	}
+	for i, decl := range file.DeclList {
+		got := ""
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			got = fmt.Sprintf("%s %d clauses", d.Name, len(d.Clauses))
+			if d.Type != nil {
+				got += fmt.Sprintf(" : %v", d.Type)
+			}
+		case *ast.TypeDecl:
+			got = fmt.Sprintf("%s :", d.Name)
+		case *ast.ImplDecl:
+			got = "impl"
+		}
+		if got != want[i] {
+			t.Errorf("Expected %s, found %s", want[i], got)
+		}
+	}
+
+	// The function spans all of its clauses
+	fact := file.DeclList[0].(*ast.FuncDecl)
+	if loc := fact.Locate(); loc.Line != 3 || fact.Clauses[1].Locate().Line != 4 {
+		t.Errorf("Expected fact and its clauses on lines 3 and 4, found %v and %v", loc, fact.Clauses[1].Locate())
+	}
+
+	// Local and method definitions are grouped too
+	where := file.DeclList[2].(*ast.FuncDecl).Clauses[0].Body.(*ast.WhereExpr)
+	if n := len(where.Decls); n != 1 || len(where.Decls[0].(*ast.FuncDecl).Clauses) != 2 {
+		t.Errorf("Expected go with 2 clauses, found %d declarations", n)
+	}
+	impl := file.DeclList[4].(*ast.ImplDecl)
+	if len(impl.Methods) != 1 || len(impl.Methods[0].Clauses) != 3 {
+		t.Errorf("Expected (==) with 3 clauses, found %d methods", len(impl.Methods))
+	}
+}
+
+func TestGroupBindingsErrors(t *testing.T) {
+	cases := []struct {
+		src, err string
+	}{
+		{
+			"f 0 = 1\ng = 2\nf n = n",
+			"group.seal:3:1: Clauses of f are not adjacent, the previous one is at 1:1",
+		},
+		{
+			"f 0 = 1\nf : Int -> Int\nf n = n",
+			"group.seal:3:1: Clauses of f are not adjacent, the previous one is at 1:1",
+		},
+		{
+			"f 0 = 1\nf m n = n",
+			"group.seal:2:1: Clause of f has 2 parameters, expected 1 as in the first one",
+		},
+		{
+			"f : Int\nf : Int\nf = 1",
+			"group.seal:2:1: Duplicate signature of f, already declared at 1:1",
+		},
+		{
+			"x = let\n    f 0 = 1\n    y = 2\n    f n = n\n  in f",
+			"group.seal:4:5: Clauses of f are not adjacent, the previous one is at 2:5",
+		},
+	}
+	for _, c := range cases {
+		_, err := ParseFile(utils.NewFileSet(), "group.seal", c.src, 0)
+		if err == nil {
+			t.Errorf("Expected an error for %q", c.src)
+			continue
+		}
+		if got := err.Error(); got != c.err {
+			t.Errorf("Expected %q, found %q", c.err, got)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// This file implements the desugaring of lists, tuples and the unit,
+// which the parser turns into applications of their constructors as
+// it reads them, and of do blocks into calls of the method of the
+// sequencing seal in scope, as in
+//
+//	seal Monad m {
+//	    bind : m a -> (a -> m b) -> m b
+//	}
+
+package syntax
+
+import (
+	"fmt"
+
+	"github.com/seal-script/sealing/ast"
+	"github.com/seal-script/sealing/utils"
+)
+
+// The names lists and the unit desugar to: the list type and its
+// constructors are the prelude's, the unit is built in.
+const (
+	ListType = "List"
+	ListNil  = "Nil"
+	ListCons = "::"
+	Unit     = "Unit" // both the type and its only value
+)
+
+// TupleName returns the name of the built-in tuple type of n elements,
+// which is also the name of its constructor: Tuple2, Tuple3, ...
+func TupleName(n int) string {
+	return fmt.Sprintf("Tuple%d", n)
+}
+
+// conName makes up the name of a constructor or type written as
+// brackets, spanning them.
+func (p *Parser) conName(name string, m mark) *ast.Name {
+	n := &ast.Name{Value: name}
+	p.finish(n, m)
+	return n
+}
+
+// listExpr desugars [x, y] into x :: y :: Nil. Each cons spans from
+// its element to the closing bracket.
+func (p *Parser) listExpr(elems []ast.Expr, m mark) ast.Expr {
+	var list ast.Expr = p.conName(ListNil, m)
+	for i := len(elems) - 1; i >= 0; i-- {
+		cons := &ast.CallExpr{Fun: p.conName(ListCons, m), ArgList: []ast.Expr{elems[i], list}}
+		p.finish(cons, p.markOf(elems[i]))
+		list = cons
+	}
+	list.SetSpan(m.loc, utils.Span{Start: p.file.Pos(m.offs), End: list.Span().End})
+	return list
+}
+
+// tupleExpr desugars (x, y) into Tuple2 x y, and () into Unit.
+func (p *Parser) tupleExpr(elems []ast.Expr, m mark) ast.Expr {
+	if len(elems) == 0 {
+		return p.conName(Unit, m)
+	}
+	tuple := &ast.CallExpr{Fun: p.conName(TupleName(len(elems)), m), ArgList: elems}
+	p.finish(tuple, m)
+	return tuple
+}
+
+// listPattern desugars [x, y] into x :: y :: Nil.
+func (p *Parser) listPattern(elems []ast.Pattern, m mark) ast.Pattern {
+	var list ast.Pattern = &ast.ConPattern{Con: p.conName(ListNil, m)}
+	p.finish(list, m)
+	for i := len(elems) - 1; i >= 0; i-- {
+		cons := &ast.ConPattern{Con: p.conName(ListCons, m), Args: []ast.Pattern{elems[i], list}, Infix: true}
+		p.finish(cons, p.markOf(elems[i]))
+		list = cons
+	}
+	list.SetSpan(m.loc, utils.Span{Start: p.file.Pos(m.offs), End: list.Span().End})
+	return list
+}
+
+// tuplePattern desugars (x, y) into Tuple2 x y, and () into Unit.
+func (p *Parser) tuplePattern(elems []ast.Pattern, m mark) ast.Pattern {
+	name := Unit
+	if len(elems) > 0 {
+		name = TupleName(len(elems))
+	}
+	tuple := &ast.ConPattern{Con: p.conName(name, m), Args: elems}
+	p.finish(tuple, m)
+	return tuple
+}
+
+// listType desugars [a] into List a.
+func (p *Parser) listType(elem ast.Type, m mark) ast.Type {
+	list := &ast.CallExpr{Fun: p.conName(ListType, m), ArgList: []ast.Expr{elem}}
+	p.finish(list, m)
+	return list
+}
+
+// tupleType desugars (a, b) into Tuple2 a b, and () into Unit.
+func (p *Parser) tupleType(elems []ast.Type, m mark) ast.Type {
+	tuple := &ast.CallExpr{Fun: p.conName(Unit, m)}
+	if len(elems) > 0 {
+		tuple.Fun = p.conName(TupleName(len(elems)), m)
+		for _, elem := range elems {
+			tuple.ArgList = append(tuple.ArgList, elem)
+		}
+	}
+	p.finish(tuple, m)
+	return tuple
+}
+
+// BindMethod returns the method of a seal which has the type of bind,
+//
+//	m a -> (a -> m b) -> m b
+//
+// m being the parameter of the seal, making it a sequencing seal.
+// It returns nil if the seal has no such method.
+func BindMethod(seal *ast.SealDecl) *ast.Name {
+	if len(seal.Params) != 1 || seal.Params[0].Name == nil {
+		return nil
+	}
+	m := seal.Params[0].Name.Value
+	for i := range seal.Fields {
+		method := &seal.Fields[i]
+		if method.Type == nil {
+			continue
+		}
+		_, params, result := ast.UncurryType(method.Type)
+		if len(params) != 2 || !appliedTo(params[0], m) || !appliedTo(result, m) {
+			continue
+		}
+		_, kParams, kResult := ast.UncurryType(params[1])
+		if len(kParams) == 1 && appliedTo(kResult, m) {
+			return method.Name
+		}
+	}
+	return nil
+}
+
+// appliedTo reports whether t is the type m x, for some x.
+func appliedTo(t ast.Type, m string) bool {
+	call, ok := t.(*ast.CallExpr)
+	if !ok || len(call.ArgList) != 1 {
+		return false
+	}
+	fun, ok := call.Fun.(*ast.Name)
+	return ok && fun.Value == m
+}
+
+// DesugarDo rewrites every do block of the file, nested ones included,
+// into calls of bind, the bind method of the sequencing seal in scope:
+//
+//	do { x <- e; rest }    is  bind e (\x -> do { rest })
+//	do { e; rest }         is  bind e (\_ -> do { rest })
+//	do { let ds; rest }    is  let ds in do { rest }
+//	do { e }               is  e
+//
+// Each node made up spans from its statement to the end of the block.
+// The file is left as it is, the result sharing with it the parts
+// without do blocks. Every do block is an error when bind is nil,
+// there being no sequencing seal in scope. So is a bind whose pattern
+// may fail to match, `Just y <- e`, a sequencing seal having no way
+// to fail: such a pattern is matched in a case instead.
+func DesugarDo(file *ast.File, bind ast.Expr) (*ast.File, error) {
+	var errors ErrorList
+	result := ast.Rewrite(file, func(n ast.Node) ast.Node {
+		do, ok := n.(*ast.DoExpr)
+		switch {
+		case !ok:
+			return n
+		case bind == nil:
+			errors = append(errors, errorOf(do.Locate(), "No sequencing seal in scope for a do block"))
+			return n
+		}
+		for _, stmt := range do.Stmts {
+			if s, ok := stmt.(*ast.BindStmt); ok && !irrefutable(s.Pattern) {
+				errors = append(errors, errorOf(s.Pattern.Locate(),
+					"The pattern %v of a bind may fail to match, match it in a case instead", s.Pattern))
+			}
+		}
+		return desugarStmts(do.Stmts, do.Span().End, bind)
+	})
+	return result.(*ast.File), errors.Err()
+}
+
+func desugarStmts(stmts []ast.Stmt, end utils.Pos, bind ast.Expr) ast.Expr {
+	stmt := stmts[0]
+	span := func(n ast.Node) {
+		n.SetSpan(stmt.Locate(), utils.Span{Start: stmt.Span().Start, End: end})
+	}
+	if len(stmts) == 1 {
+		return stmt.(*ast.ExprStmt).X
+	}
+	rest := desugarStmts(stmts[1:], end, bind)
+
+	var x ast.Expr
+	var pattern ast.Pattern
+	switch s := stmt.(type) {
+	case *ast.LetStmt:
+		let := &ast.LetExpr{Decls: s.Decls, Body: rest}
+		span(let)
+		return let
+	case *ast.BindStmt:
+		x, pattern = s.X, s.Pattern
+	case *ast.ExprStmt:
+		x, pattern = s.X, new(ast.WildcardPattern)
+		pattern.SetSpan(s.Locate(), s.Span())
+	}
+	lambda := &ast.LambdaExpr{Params: []ast.Pattern{pattern}, Body: rest}
+	span(lambda)
+	call := &ast.CallExpr{Fun: bindAt(bind, stmt), ArgList: []ast.Expr{x, lambda}}
+	span(call)
+	return call
+}
+
+// irrefutable reports whether a pattern always matches: a variable,
+// a wildcard, or a tuple or the unit of such patterns.
+func irrefutable(pattern ast.Pattern) bool {
+	switch p := pattern.(type) {
+	case *ast.VarPattern, *ast.WildcardPattern:
+		return true
+	case *ast.TypedPattern:
+		return irrefutable(p.Pattern)
+	case *ast.AsPattern:
+		return irrefutable(p.Pattern)
+	case *ast.ConPattern:
+		if p.Con.Value != TupleName(len(p.Args)) && !(p.Con.Value == Unit && len(p.Args) == 0) {
+			return false
+		}
+		for _, arg := range p.Args {
+			if !irrefutable(arg) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// bindAt returns a copy of bind, a name qualified or not, spanning
+// the statement it desugars.
+func bindAt(bind ast.Expr, stmt ast.Stmt) ast.Expr {
+	return ast.Rewrite(bind, func(n ast.Node) ast.Node {
+		switch n := n.(type) {
+		case *ast.Name:
+			c := *n
+			c.SetSpan(stmt.Locate(), stmt.Span())
+			return &c
+		case *ast.SelectorExpr:
+			c := *n
+			c.SetSpan(stmt.Locate(), stmt.Span())
+			return &c
+		}
+		return n
+	}).(ast.Expr)
+}
@@ -0,0 +1,113 @@
+package syntax
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/seal-script/sealing/ast"
+	"github.com/seal-script/sealing/utils"
+)
+
+func TestBindMethod(t *testing.T) {
+	cases := []struct {
+		src, want string // want is "" when the seal is not a sequencing one
+	}{
+		{"seal Monad m { pure : a -> m a; bind : m a -> (a -> m b) -> m b }", "bind"},
+		{"seal Monad m { (>>=) : m a -> (a -> m b) -> m b }", ">>="},
+		{"seal Functor f { map : (a -> b) -> f a -> f b }", ""},
+		{"seal Bind m n { bind : m a -> (a -> m b) -> m b }", ""},
+		{"seal Monad m { bind : m a -> (a -> b) -> m b }", ""},
+	}
+	for _, c := range cases {
+		file, err := ParseFile(utils.NewFileSet(), "seal.seal", c.src, 0)
+		if err != nil {
+			t.Fatalf("Parsing %q: %v", c.src, err)
+		}
+		got := ""
+		if method := BindMethod(file.DeclList[0].(*ast.SealDecl)); method != nil {
+			got = method.Value
+		}
+		if got != c.want {
+			t.Errorf("%s: expected %q, found %q", c.src, c.want, got)
+		}
+	}
+}
+
+func TestDesugarDo(t *testing.T) {
+	bind := &ast.Name{Value: "bind"}
+	cases := []struct {
+		src, want string
+	}{
+		{"x = do { get }", "get"},
+		{"x = do { y <- get; put y }", "(bind get (\\[y] -> (put [y])))"},
+		{"x = do { tick; get }", "(bind tick (\\[_] -> get))"},
+		{"x = do { let { y = 1 }; put y }", "(let 1 decls in (put [y]))"},
+		{
+			"x = do { (y, _) <- get; tick; pure (y + 1) }",
+			"(bind get (\\[(Tuple2 y _)] -> (bind [tick (\\[_] -> (pure [(+ [y 1])]))])))",
+		},
+		{
+			"x = do { y <- do { tick; get }; put y }",
+			"(bind (bind tick (\\[_] -> get)) (\\[y] -> (put [y])))",
+		},
+	}
+	for _, c := range cases {
+		file, err := resolved(t, c.src, nil)
+		if err != nil {
+			t.Errorf("%s: %v", c.src, err)
+			continue
+		}
+		result, err := DesugarDo(file, bind)
+		if err != nil {
+			t.Errorf("%s: %v", c.src, err)
+			continue
+		}
+		if got := sexpr(result.DeclList[0].(*ast.FuncDecl).Clauses[0].Body); got != c.want {
+			t.Errorf("Expected %s, found %s", c.want, got)
+		}
+		if _, ok := file.DeclList[0].(*ast.FuncDecl).Clauses[0].Body.(*ast.DoExpr); !ok {
+			t.Errorf("%s: expected the do block to be kept in the original file", c.src)
+		}
+	}
+
+	// The calls span from their statement to the end of the block
+	src := "x = do\n    y <- get\n    put y\n"
+	file, err := ParseFile(utils.NewFileSet(), "do.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	do := file.DeclList[0].(*ast.FuncDecl).Clauses[0].Body.(*ast.DoExpr)
+	result, err := DesugarDo(file, bind)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := result.DeclList[0].(*ast.FuncDecl).Clauses[0].Body.(*ast.CallExpr)
+	if loc := call.Locate(); loc.Line != 2 || loc.Col != 5 || call.Span().End != do.Span().End {
+		t.Errorf("Expected the bind to span from 2:5 to the end of the block, found %v", fmt.Sprint(loc))
+	}
+
+	// Without a sequencing seal in scope
+	_, err = DesugarDo(file, nil)
+	if want := "do.seal:1:5: No sequencing seal in scope for a do block"; err == nil || err.Error() != want {
+		t.Errorf("Expected %q, found %v", want, err)
+	}
+
+	// Patterns which may fail to match
+	for _, c := range []struct {
+		src, err string
+	}{
+		{"x = do { Just y <- get; put y }", "fixity.seal:1:10: The pattern (Just y) of a bind may fail to match, match it in a case instead"},
+		{"x = do { (y :: _) <- get; put y }", "fixity.seal:1:11: The pattern (y :: _) of a bind may fail to match, match it in a case instead"},
+		{"x = do { (Just a, b) <- get; put b }", "fixity.seal:1:10: The pattern (Tuple2 (Just a) b) of a bind may fail to match, match it in a case instead"},
+		{"x = do { tick; 0 <- get; tick }", "fixity.seal:1:16: The pattern 0 of a bind may fail to match, match it in a case instead"},
+	} {
+		file, err := resolved(t, c.src, nil)
+		if err != nil {
+			t.Errorf("%s: %v", c.src, err)
+			continue
+		}
+		if _, err := DesugarDo(file, bind); err == nil || err.Error() != c.err {
+			t.Errorf("%s: expected %q, found %v", c.src, c.err, err)
+		}
+	}
+}
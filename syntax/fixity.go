@@ -0,0 +1,392 @@
+// This file implements the resolution of infix operators. The
+// parser leaves every chain of infix applications flat, in an
+// ast.InfixExpr, because the fixity of an operator may be declared
+// in a module imported later on. Once the fixities are known,
+// ResolveOperators rebuilds the chains into nested applications,
+// following the algorithm of the Haskell report (section 10.6).
+
+package syntax
+
+import (
+	"fmt"
+
+	"github.com/seal-script/sealing/ast"
+	"github.com/seal-script/sealing/utils"
+)
+
+// A Fixity tells how tightly an infix operator binds
+type Fixity struct {
+	Assoc ast.Assoc
+	Prec  int // from 0 to 9
+}
+
+func (f Fixity) String() string {
+	return fmt.Sprintf("%s %d", f.Assoc, f.Prec)
+}
+
+// Operators without a fixity declaration
+var DefaultFixity = Fixity{ast.InfixLeft, 9}
+
+// Fixities maps operators to their fixity
+type Fixities map[string]Fixity
+
+// The fixities of the operators of the prelude
+var PreludeFixities = Fixities{
+	"$":   {ast.InfixRight, 0},
+	"||":  {ast.InfixRight, 2},
+	"&&":  {ast.InfixRight, 3},
+	"==":  {ast.InfixNone, 4},
+	"!=":  {ast.InfixNone, 4},
+	"<":   {ast.InfixNone, 4},
+	"<=":  {ast.InfixNone, 4},
+	">":   {ast.InfixNone, 4},
+	">=":  {ast.InfixNone, 4},
+	"<$>": {ast.InfixLeft, 4},
+	"::":  {ast.InfixRight, 5},
+	"++":  {ast.InfixRight, 5},
+	"<>":  {ast.InfixRight, 6},
+	"+":   {ast.InfixLeft, 6},
+	"-":   {ast.InfixLeft, 6},
+	"*":   {ast.InfixLeft, 7},
+	"/":   {ast.InfixLeft, 7},
+	"div": {ast.InfixLeft, 7},
+	"mod": {ast.InfixLeft, 7},
+	"^":   {ast.InfixRight, 8},
+	".":   {ast.InfixRight, 9},
+}
+
+// Prefix `-` binds like an infix `-`
+var negateFixity = Fixity{ast.InfixLeft, 6}
+
+// FixitiesOf returns the fixities declared in a file.
+func FixitiesOf(file *ast.File) Fixities {
+	fixities := Fixities{}
+	for _, decl := range file.DeclList {
+		if d, ok := decl.(*ast.FixityDecl); ok {
+			for _, op := range d.Ops {
+				fixities[op.Value] = Fixity{d.Assoc, d.Prec}
+			}
+		}
+	}
+	return fixities
+}
+
+// ResolveOperators rewrites every infix expression of the file into
+// nested applications, `a + b * c` into `(+) a ((*) b c)`, and turns
+// each prefix `-` into an application of `negate`. Chains of infix
+// constructors in patterns become nested constructor patterns. The
+// fixities of the prelude come first, then the imported ones, then
+// the ones declared in the file. Sections are kept, with their
+// operands resolved.
+func ResolveOperators(file *ast.File, imported Fixities) error {
+	r := &resolver{fixities: Fixities{}}
+	for op, fixity := range PreludeFixities {
+		r.fixities[op] = fixity
+	}
+	for op, fixity := range imported {
+		r.fixities[op] = fixity
+	}
+	for op, fixity := range FixitiesOf(file) {
+		r.fixities[op] = fixity
+	}
+
+	for _, decl := range file.DeclList {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			r.resolveFunc(d)
+		case *ast.SealDecl:
+			for _, def := range d.Defaults {
+				r.resolveFunc(def)
+			}
+		case *ast.ImplDecl:
+			for _, method := range d.Methods {
+				r.resolveFunc(method)
+			}
+			if d.Value != nil {
+				d.Value = r.resolve(d.Value)
+			}
+		}
+	}
+	return r.errors.Err()
+}
+
+type resolver struct {
+	fixities Fixities
+	errors   ErrorList
+}
+
+func (r *resolver) fixity(op *ast.Name) Fixity {
+	if fixity, ok := r.fixities[op.Value]; ok {
+		return fixity
+	}
+	return DefaultFixity
+}
+
+func (r *resolver) errorf(n ast.Node, format string, args ...any) {
+	r.errors = append(r.errors, errorOf(n.Locate(), format, args...))
+}
+
+func (r *resolver) resolveFunc(decl *ast.FuncDecl) {
+	for _, clause := range decl.Clauses {
+		r.resolvePatterns(clause.Params)
+		clause.Body = r.resolve(clause.Body)
+	}
+}
+
+func (r *resolver) resolveDecls(decls []ast.Decl) {
+	for _, decl := range decls {
+		if d, ok := decl.(*ast.FuncDecl); ok {
+			r.resolveFunc(d)
+		}
+	}
+}
+
+// resolve returns expr with all the infix expressions inside resolved.
+func (r *resolver) resolve(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		e.Fun = r.resolve(e.Fun)
+		for i, arg := range e.ArgList {
+			e.ArgList[i] = r.resolve(arg)
+		}
+		return e
+
+	case *ast.LambdaExpr:
+		r.resolvePatterns(e.Params)
+		e.Body = r.resolve(e.Body)
+		return e
+
+	case *ast.LetExpr:
+		r.resolveDecls(e.Decls)
+		e.Body = r.resolve(e.Body)
+		return e
+
+	case *ast.WhereExpr:
+		e.Body = r.resolve(e.Body)
+		r.resolveDecls(e.Decls)
+		return e
+
+	case *ast.IfExpr:
+		e.Cond = r.resolve(e.Cond)
+		e.Then = r.resolve(e.Then)
+		e.Else = r.resolve(e.Else)
+		return e
+
+	case *ast.CaseExpr:
+		e.X = r.resolve(e.X)
+		for _, alt := range e.Alts {
+			alt.Pattern = r.resolvePattern(alt.Pattern)
+			alt.Body = r.resolve(alt.Body)
+		}
+		return e
+
+	case *ast.SelectorExpr:
+		e.X = r.resolve(e.X)
+		return e
+
+	case *ast.RecordExpr:
+		r.resolveFields(e.Fields)
+		return e
+
+	case *ast.UpdateExpr:
+		e.X = r.resolve(e.X)
+		r.resolveFields(e.Fields)
+		return e
+
+	case *ast.DoExpr:
+		for _, stmt := range e.Stmts {
+			switch s := stmt.(type) {
+			case *ast.BindStmt:
+				s.Pattern = r.resolvePattern(s.Pattern)
+				s.X = r.resolve(s.X)
+			case *ast.LetStmt:
+				r.resolveDecls(s.Decls)
+			case *ast.ExprStmt:
+				s.X = r.resolve(s.X)
+			}
+		}
+		return e
+
+	case *ast.GuardedExpr:
+		for _, alt := range e.Alts {
+			for _, guard := range alt.Guards {
+				if guard.Pattern != nil {
+					guard.Pattern = r.resolvePattern(guard.Pattern)
+				}
+				guard.X = r.resolve(guard.X)
+			}
+			alt.Body = r.resolve(alt.Body)
+		}
+		return e
+
+	case *ast.InfixExpr:
+		return r.resolveChain(r.tokensOf(e))
+
+	case *ast.NegExpr:
+		return r.resolveChain(r.tokensOf(&ast.InfixExpr{Exprs: []ast.Expr{e}}))
+
+	case *ast.SectionExpr:
+		// The operand of a section must bind tighter than its operator,
+		// as if the missing operand were there
+		hole := new(ast.BadExpr)
+		var chain []chainToken
+		if e.Left != nil {
+			chain = append(r.operandTokens(e.Left), chainToken{op: e.Op}, chainToken{operand: hole})
+		} else {
+			chain = append([]chainToken{{operand: hole}, {op: e.Op}}, r.operandTokens(e.Right)...)
+		}
+		errors := len(r.errors)
+		resolved, ok := r.resolveChain(chain).(*ast.CallExpr)
+		switch {
+		case len(r.errors) > errors:
+			// Already reported
+		case ok && resolved.Fun == e.Op && e.Left != nil && resolved.ArgList[1] == hole:
+			e.Left = resolved.ArgList[0]
+		case ok && resolved.Fun == e.Op && e.Right != nil && resolved.ArgList[0] == hole:
+			e.Right = resolved.ArgList[1]
+		default:
+			r.errorf(e, "The operand of a section of %s [%s] must be in parentheses", e.Op, r.fixity(e.Op))
+		}
+		return e
+
+	default:
+		return expr
+	}
+}
+
+func (r *resolver) resolveFields(fields []*ast.FieldValue) {
+	for _, field := range fields {
+		if field.Value != nil {
+			field.Value = r.resolve(field.Value)
+		}
+	}
+}
+
+func (r *resolver) resolvePatterns(patterns []ast.Pattern) {
+	for i, pattern := range patterns {
+		patterns[i] = r.resolvePattern(pattern)
+	}
+}
+
+// resolvePattern returns pattern with all the infix patterns inside resolved.
+func (r *resolver) resolvePattern(pattern ast.Pattern) ast.Pattern {
+	switch p := pattern.(type) {
+	case *ast.ConPattern:
+		r.resolvePatterns(p.Args)
+	case *ast.AsPattern:
+		p.Pattern = r.resolvePattern(p.Pattern)
+	case *ast.TypedPattern:
+		p.Pattern = r.resolvePattern(p.Pattern)
+	case *ast.RecordPattern:
+		for _, field := range p.Fields {
+			if field.Pattern != nil {
+				field.Pattern = r.resolvePattern(field.Pattern)
+			}
+		}
+	case *ast.InfixPattern:
+		tokens := []chainToken{{operand: r.resolvePattern(p.Patterns[0])}}
+		for i, op := range p.Ops {
+			tokens = append(tokens, chainToken{op: op}, chainToken{operand: r.resolvePattern(p.Patterns[i+1])})
+		}
+		c := &chainResolver{resolver: r, tokens: tokens, apply: applyCon}
+		return c.parseNeg(Fixity{ast.InfixNone, -1}).(ast.Pattern)
+	}
+	return pattern
+}
+
+// A chainToken is an operand, an operator or a prefix `-`
+type chainToken struct {
+	operand ast.Node  // an expression, or a pattern
+	op      *ast.Name // an infix operator
+	neg     *ast.NegExpr
+}
+
+// tokensOf flattens a chain into tokens, resolving the operands.
+func (r *resolver) tokensOf(chain *ast.InfixExpr) []chainToken {
+	tokens := r.operandTokens(chain.Exprs[0])
+	for i, op := range chain.Ops {
+		tokens = append(tokens, chainToken{op: op})
+		tokens = append(tokens, r.operandTokens(chain.Exprs[i+1])...)
+	}
+	return tokens
+}
+
+// operandTokens returns the tokens of an operand of a chain, more
+// than one when it is a negation or a chain itself, as it is when
+// it comes from a section.
+func (r *resolver) operandTokens(expr ast.Expr) []chainToken {
+	switch e := expr.(type) {
+	case *ast.InfixExpr:
+		return r.tokensOf(e)
+	case *ast.NegExpr:
+		return append([]chainToken{{neg: e}}, r.operandTokens(e.X)...)
+	default:
+		return []chainToken{{operand: r.resolve(expr)}}
+	}
+}
+
+// resolveChain builds the tree of a chain of tokens. On error it
+// reports it and goes on as if the operators associated to the left.
+func (r *resolver) resolveChain(tokens []chainToken) ast.Expr {
+	c := &chainResolver{resolver: r, tokens: tokens, apply: applyOp}
+	return c.parseNeg(Fixity{ast.InfixNone, -1}).(ast.Expr)
+}
+
+type chainResolver struct {
+	*resolver
+	tokens []chainToken
+	apply  func(op *ast.Name, left, right ast.Node) ast.Node
+}
+
+// applyOp applies an operator to its operands, `(+) a b`
+func applyOp(op *ast.Name, left, right ast.Node) ast.Node {
+	call := &ast.CallExpr{Fun: op, ArgList: []ast.Expr{left.(ast.Expr), right.(ast.Expr)}}
+	call.SetSpan(left.Locate(), utils.Span{Start: left.Span().Start, End: right.Span().End})
+	return call
+}
+
+// applyCon applies a constructor to its patterns, `x :: xs`
+func applyCon(op *ast.Name, left, right ast.Node) ast.Node {
+	con := &ast.ConPattern{Con: op, Args: []ast.Pattern{left.(ast.Pattern), right.(ast.Pattern)}, Infix: true}
+	con.SetSpan(left.Locate(), utils.Span{Start: left.Span().Start, End: right.Span().End})
+	return con
+}
+
+// parseNeg parses an operand, which may be negated, and the
+// operators following it which bind tighter than op1.
+func (c *chainResolver) parseNeg(op1 Fixity) ast.Node {
+	tok := c.tokens[0]
+	c.tokens = c.tokens[1:]
+	if tok.neg == nil {
+		return c.parse1(op1, tok.operand)
+	}
+	neg := negateFixity
+	if op1.Prec >= neg.Prec {
+		c.errorf(tok.neg, "Cannot mix prefix `-` [%s] with an operator of fixity [%s]", neg, op1)
+	}
+	x := c.parseNeg(neg).(ast.Expr)
+	negate := &ast.Name{Value: "negate"}
+	negate.SetSpan(tok.neg.Locate(), utils.Span{Start: tok.neg.Span().Start, End: tok.neg.Span().Start + 1})
+	call := &ast.CallExpr{Fun: negate, ArgList: []ast.Expr{x}}
+	call.SetSpan(tok.neg.Locate(), utils.Span{Start: tok.neg.Span().Start, End: x.Span().End})
+	return c.parse1(op1, call)
+}
+
+// parse1 continues the operand e1 with the operators following it
+// which bind tighter than op1.
+func (c *chainResolver) parse1(op1 Fixity, e1 ast.Node) ast.Node {
+	for len(c.tokens) > 0 {
+		op := c.tokens[0].op
+		op2 := c.fixity(op)
+		if op1.Prec == op2.Prec && (op1.Assoc != op2.Assoc || op1.Assoc == ast.InfixNone) {
+			c.errorf(op, "Cannot mix operators of fixity [%s] and %s [%s] in the same infix expression", op1, op, op2)
+		}
+		if op1.Prec > op2.Prec || op1.Prec == op2.Prec && op1.Assoc == ast.InfixLeft {
+			return e1
+		}
+		c.tokens = c.tokens[1:]
+		e2 := c.parseNeg(op2)
+		e1 = c.apply(op, e1, e2)
+	}
+	return e1
+}
@@ -0,0 +1,140 @@
+package syntax
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/seal-script/sealing/ast"
+	"github.com/seal-script/sealing/utils"
+)
+
+// sexpr prints a resolved expression with every application in parentheses
+func sexpr(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.CallExpr:
+		parts := []string{sexpr(e.Fun)}
+		for _, arg := range e.ArgList {
+			parts = append(parts, sexpr(arg))
+		}
+		return "(" + strings.Join(parts, " ") + ")"
+	case *ast.SectionExpr:
+		if e.Left == nil {
+			return fmt.Sprintf("(_ %s %s)", e.Op, sexpr(e.Right))
+		}
+		return fmt.Sprintf("(%s %s _)", sexpr(e.Left), e.Op)
+	default:
+		return fmt.Sprint(e)
+	}
+}
+
+// resolved parses src and resolves its operators
+func resolved(t *testing.T, src string, imported Fixities) (*ast.File, error) {
+	file, err := ParseFile(utils.NewFileSet(), "fixity.seal", src, 0)
+	if err != nil {
+		t.Fatalf("Parsing %q: %v", src, err)
+	}
+	return file, ResolveOperators(file, imported)
+}
+
+func TestResolveOperators(t *testing.T) {
+	cases := []struct {
+		src, want string
+	}{
+		{"x = fact (n - 1) + fact (n - 2)", "(+ (fact (- n 1)) (fact (- n 2)))"},
+		{"x = a + b * c", "(+ a (* b c))"},
+		{"x = a - b - c", "(- (- a b) c)"},
+		{"x = x :: y :: xs", "(:: x (:: y xs))"},
+		{"x = f $ g $ h x", "($ f ($ g (h x)))"},
+		{"x = a `div` b + 1", "(+ (div a b) 1)"},
+		{"x = a `op` b `op` c", "(op (op a b) c)"},
+		{"x = - a * b", "(negate (* a b))"},
+		{"x = - a + b", "(+ (negate a) b)"},
+		{"x = a == - b", "(== a (negate b))"},
+		{"x = f (- 1)", "(f (negate 1))"},
+		{"x = f . g . h", "(. f (. g h))"},
+		{"x = (. g)", "(_ . g)"},
+		{"x = (f .)", "(f . _)"},
+		{"x = (.)", "."},
+		{"x = (<> x)", "(_ <> x)"},
+		{"x = (x <>)", "(x <> _)"},
+		{"x = (a * b +)", "((* a b) + _)"},
+		{"x = (+ a * b)", "(_ + (* a b))"},
+		{"x = (`div` 2)", "(_ div 2)"},
+		{"x = (-) n 1", "(- n 1)"},
+		{"infixr 9 >>\nx = f >> g >> h", "(>> f (>> g h))"},
+		{"infixl 1 |>\nx = a |> f |> g", "(|> (|> a f) g)"},
+	}
+	for _, c := range cases {
+		file, err := resolved(t, c.src, nil)
+		if err != nil {
+			t.Errorf("Resolving %q: %v", c.src, err)
+			continue
+		}
+		decl := file.DeclList[len(file.DeclList)-1].(*ast.FuncDecl)
+		if got := sexpr(decl.Clauses[0].Body); got != c.want {
+			t.Errorf("Resolving %q: expected %s, found %s", c.src, c.want, got)
+		}
+	}
+}
+
+func TestResolveImportedFixities(t *testing.T) {
+	imported := Fixities{"<+>": {ast.InfixRight, 5}}
+	file, err := resolved(t, "x = a <+> b <+> c * d", imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sexpr(file.DeclList[0].(*ast.FuncDecl).Clauses[0].Body), "(<+> a (<+> b (* c d)))"; got != want {
+		t.Errorf("Expected %s, found %s", want, got)
+	}
+
+	// Local declarations win over imported ones
+	file, err = resolved(t, "infixl 5 <+>\nx = a <+> b <+> c", imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sexpr(file.DeclList[1].(*ast.FuncDecl).Clauses[0].Body), "(<+> (<+> a b) c)"; got != want {
+		t.Errorf("Expected %s, found %s", want, got)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		line uint
+		col  uint
+	}{
+		{"x = a == b == c", 1, 12},
+		{"infixl 6 +.\ninfixr 6 .+\nx = a +. b .+ c", 3, 12},
+		{"x = a + - b", 1, 9},
+		{"x = (a + b *)", 1, 5},
+		{"x = (* a + b)", 1, 5},
+	}
+	for _, c := range cases {
+		_, err := resolved(t, c.src, nil)
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) != 1 {
+			t.Errorf("Resolving %q: expected one error, found %v", c.src, err)
+			continue
+		}
+		if loc := errs[0].Location; loc.Line != c.line || loc.Col != c.col {
+			t.Errorf("Resolving %q: expected an error at %d:%d, found %v", c.src, c.line, c.col, errs[0])
+		}
+	}
+}
+
+func TestFixityDecl(t *testing.T) {
+	file, err := ParseFile(utils.NewFileSet(), "fixity.seal", "infixr 5 ::, ++\ninfix `elem`\ninfixl 10 +", AllErrors)
+	if err == nil {
+		t.Fatal("Expected an error for precedence 10")
+	}
+	fixities := FixitiesOf(file)
+	want := Fixities{
+		"::":   {ast.InfixRight, 5},
+		"++":   {ast.InfixRight, 5},
+		"elem": {ast.InfixNone, 9},
+	}
+	if fmt.Sprint(fixities) != fmt.Sprint(want) {
+		t.Errorf("Expected fixities %v, found %v", want, fixities)
+	}
+}
@@ -0,0 +1,237 @@
+// This file implements layout, the offside rule pass that
+// sits between the scanner and the parser. It tracks the
+// column of every block opener and inserts the virtual
+// '{', ';' and '}' tokens that the parser relies on, much
+// like the layout algorithm of the Haskell report.
+
+package syntax
+
+import (
+	"io"
+)
+
+type contextKind int
+
+const (
+	topContext      contextKind = iota // the implicit block holding top-level declarations
+	implicitContext                    // a block opened by a layout keyword
+	braceContext                       // an explicit '{' ... '}' block
+	parenContext                       // '(' ... ')' or '[' ... ']', newlines are insignificant inside
+)
+
+// A layout context is one entry of the layout stack.
+type context struct {
+	kind   contextKind
+	col    uint     // column of the first token of the block, 0 if not yet known
+	opener tokenTag // the keyword which opened an implicit block
+}
+
+// layout words open an implicit block unless followed by '{'
+var layoutWords = map[tokenTag]bool{
+	_Where: true,
+	_Let:   true,
+	_Of:    true,
+	_Do:    true,
+}
+
+// A token waiting in the layout queue
+type queued struct {
+	Token
+	level int // number of open contexts when the token was queued
+}
+
+type layout struct {
+	scanner
+	token Token // current token, shadows scanner.token
+	level int   // nesting level of the current token, 1 at the top level
+
+	stack    []context
+	pending  []queued // tokens waiting to be handed out, in order
+	opener   tokenTag // the layout word which set expect
+	expect   bool     // the previous token was a layout word
+	started  bool     // the top-level context has been pushed
+	comments []Token  // comments right before the most recent source token
+}
+
+func (l *layout) init(
+	src io.Reader,
+	errh func(line, col uint, msg string),
+	mode uint,
+) {
+	l.scanner.init(src, errh, mode)
+	l.token = Token{}
+	l.level = 0
+	l.stack = l.stack[:0]
+	l.pending = l.pending[:0]
+	l.expect = false
+	l.started = false
+	l.comments = l.comments[:0]
+}
+
+// next advances to the next token, which is either a virtual
+// token inserted by the offside rule or a token of the source.
+func (l *layout) next() error {
+	if len(l.pending) == 0 {
+		if err := l.fill(); err != nil {
+			return err
+		}
+	}
+	l.token = l.pending[0].Token
+	l.level = l.pending[0].level
+	l.pending = l.pending[1:]
+	return nil
+}
+
+// peek returns the token after the current one without advancing.
+// It may scan ahead, so it is only meant for the inside of expressions,
+// where comments don't matter.
+func (l *layout) peek() (Token, error) {
+	if len(l.pending) == 0 {
+		if err := l.fill(); err != nil {
+			return Token{}, err
+		}
+	}
+	return l.pending[0].Token, nil
+}
+
+// fill scans one source token and queues it together with
+// the virtual tokens that have to precede it. Comments are
+// kept aside in l.comments and never reach the parser.
+func (l *layout) fill() error {
+	bol := false // token is the first one on its line, comments aside
+	l.comments = l.comments[:0]
+	for {
+		if err := l.scanner.next(); err != nil {
+			return err
+		}
+		bol = l.scanner.blank || bol && len(l.comments) > 0
+		if l.scanner.token.tag != _Comment {
+			break
+		}
+		l.comments = append(l.comments, l.scanner.token)
+	}
+	tok := l.scanner.token
+	col := l.scanner.col
+
+	if tok.tag == _EOF {
+		for len(l.stack) > 0 {
+			if l.top().kind == implicitContext {
+				l.emit(_BraceRight, "")
+			}
+			l.pop()
+		}
+		l.emit(_EOF, "")
+		return nil
+	}
+
+	switch {
+	case !l.started:
+		l.started = true
+		l.push(context{kind: topContext, col: col})
+
+	case l.expect:
+		opener := l.opener
+		l.expect = false
+		if tok.tag == _BraceLeft {
+			break
+		}
+		if col > l.enclosing() {
+			l.emit(_BraceLeft, "")
+			l.push(context{kind: implicitContext, col: col, opener: opener})
+			break
+		}
+		// The block is empty
+		l.emit(_BraceLeft, "")
+		l.emit(_BraceRight, "")
+		l.offside(tok, col, bol)
+
+	case l.top().kind == braceContext && l.top().col == 0:
+		l.top().col = col
+
+	default:
+		l.offside(tok, col, bol)
+	}
+
+	switch tok.tag {
+	case _ParentLeft, _BracketLeft:
+		l.push(context{kind: parenContext})
+
+	case _ParentRight, _BracketRight:
+		l.close(parenContext)
+
+	case _BraceLeft:
+		l.push(context{kind: braceContext})
+
+	case _BraceRight:
+		l.close(braceContext)
+
+	case _In:
+		// `let ... in` on a single line
+		if l.top().kind == implicitContext && l.top().opener == _Let {
+			l.emit(_BraceRight, "")
+			l.pop()
+		}
+	}
+
+	if layoutWords[tok.tag] {
+		l.opener = tok.tag
+		l.expect = true
+	}
+	l.pending = append(l.pending, queued{tok, len(l.stack)})
+	return nil
+}
+
+// offside applies the offside rule to the first token of a line.
+func (l *layout) offside(tok Token, col uint, bol bool) {
+	if !bol {
+		return
+	}
+	for l.top().kind == implicitContext && col < l.top().col {
+		l.emit(_BraceRight, "")
+		l.pop()
+	}
+	switch l.top().kind {
+	case topContext, implicitContext, braceContext:
+		if col == l.top().col && tok.tag != _BraceRight {
+			l.emit(_Semi, "\n")
+		}
+	}
+}
+
+// close pops the innermost context of the given kind, ending
+// every implicit block that is still open inside it.
+func (l *layout) close(kind contextKind) {
+	for i := len(l.stack) - 1; i > 0; i-- {
+		if l.stack[i].kind == kind {
+			for len(l.stack) > i {
+				if l.top().kind == implicitContext {
+					l.emit(_BraceRight, "")
+				}
+				l.pop()
+			}
+			return
+		}
+	}
+	// Unbalanced, leave it to the parser to complain
+}
+
+// enclosing returns the column of the innermost layout-sensitive context.
+func (l *layout) enclosing() uint {
+	for i := len(l.stack) - 1; i >= 0; i-- {
+		if l.stack[i].kind != parenContext {
+			return l.stack[i].col
+		}
+	}
+	return 0
+}
+
+// emit queues a virtual token, placed right before the source token.
+func (l *layout) emit(tag tokenTag, lit string) {
+	pos := l.scanner.token.start
+	tok := Token{tag: tag, lit: lit, start: pos, end: pos}
+	l.pending = append(l.pending, queued{tok, len(l.stack)})
+}
+
+func (l *layout) push(ctx context) { l.stack = append(l.stack, ctx) }
+func (l *layout) pop()             { l.stack = l.stack[:len(l.stack)-1] }
+func (l *layout) top() *context    { return &l.stack[len(l.stack)-1] }
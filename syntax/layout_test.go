@@ -0,0 +1,93 @@
+package syntax
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func newLayout(t *testing.T, in io.Reader) layout {
+	l := layout{}
+	errh := func(r, c uint, msg string) {
+		t.Logf("[error] Source error at (%d, %d): %s\n", r, c, msg)
+	}
+	l.init(in, errh, comments)
+	return l
+}
+
+// layoutOf renders the token stream of src, writing virtual tokens as
+// '{', ';' and '}' and source tokens by their literal.
+func layoutOf(t *testing.T, src string) string {
+	l := newLayout(t, bytes.NewReader([]byte(src)))
+	toks := []string{}
+	for l.token.tag != _EOF {
+		if err := l.next(); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case l.token.tag == _EOF:
+		case l.token.tag == _Semi:
+			toks = append(toks, ";")
+		case l.token.tag == _BraceLeft && l.token.lit == "":
+			toks = append(toks, "{")
+		case l.token.tag == _BraceRight && l.token.lit == "":
+			toks = append(toks, "}")
+		default:
+			toks = append(toks, l.token.lit)
+		}
+	}
+	return strings.Join(toks, " ")
+}
+
+func TestLayout(t *testing.T) {
+	cases := []struct {
+		src, want string
+	}{
+		{
+			"test : Int -> Int\ntest x = add x 1\n",
+			"test : Int -> Int ; test x = add x 1",
+		},
+		{
+			"\n\t\tf x =\n\t\t\tg x\n\t\th = 1\n\t",
+			"f x = g x ; h = 1",
+		},
+		{
+			"f xs = case xs of\n    Nil -> 0\n    Cons -> 1\n\ng = 2",
+			"f xs = case xs of { Nil -> 0 ; Cons -> 1 } ; g = 2",
+		},
+		{
+			"main = do\n  a\n  b\n",
+			"main = do { a ; b }",
+		},
+		{
+			"f = let x = 1 in x",
+			"f = let { x = 1 } in x",
+		},
+		{
+			"f = (case x of A -> 1)",
+			"f = ( case x of { A -> 1 } )",
+		},
+		{
+			"enum List a {\n    Nil  : List a\n    Cons : a -> List a -> List a\n}\nx = 1",
+			"enum List a { Nil : List a ; Cons : a -> List a -> List a } ; x = 1",
+		},
+		{
+			"module example (\n    fact,\n    List\n)\n\nfact = 1",
+			"module example ( fact , List ) ; fact = 1",
+		},
+		{
+			"-- | doc\nf = 1 -- trailing\n  -- indented\n{- block\n-}\ng = 2",
+			"f = 1 ; g = 2",
+		},
+		{
+			"f = x where\ng = 1",
+			"f = x where { } ; g = 1",
+		},
+	}
+	for _, c := range cases {
+		if got := layoutOf(t, c.src); got != c.want {
+			t.Errorf("layout of %q:\n\tgot  %s\n\twant %s", c.src, got, c.want)
+		}
+	}
+}
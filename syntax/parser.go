@@ -1,10 +1,13 @@
 package syntax
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"os"
 	"strconv"
-	"testing"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/seal-script/sealing/ast"
 	"github.com/seal-script/sealing/utils"
@@ -12,7 +15,7 @@ import (
 
 // A Location is a triple: (<File>, <line>, <col>)
 type Location = utils.Location
-type TokenStream = scanner
+type TokenStream = layout
 
 // An interface for parsing
 type Parsing interface {
@@ -20,54 +23,162 @@ type Parsing interface {
 	ParseExpr() (ast.Expr, error)
 }
 
-// Error type for parsing
-type ParsingError struct {
-	error
-	Location Location
-}
+// A ParsingError is an error found while parsing a file
+type ParsingError = utils.Error
+
+// An ErrorList collects every ParsingError found in a file.
+type ErrorList = utils.ErrorList
 
 // Error wrapper
 func errorOf(location Location, format string, args ...any) ParsingError {
-	return ParsingError{
-		error:    fmt.Errorf(format, args...),
-		Location: location,
-	}
+	return utils.Errorf(location, format, args...)
 }
 
-func (pErr *ParsingError) String() string {
-	return fmt.Sprintf(
-		`
-ParsingError {
-	%v,
-	location: %v,
+// The main parser
+type Parser struct {
+	layout
+	file    *utils.File
+	mode    Mode
+	prevEnd int // end offset of the last source token consumed
+
+	errh   func(error) // called with each error as it is found
+	errors ErrorList
+
+	traceOut io.Writer // where the Trace mode prints to
+	indent   int       // trace indentation level
+
+	blockLevel int // nesting level of the items of the innermost block, 1 at the top level
+	fieldLevel int // nesting level of the fields of the innermost record expression, 0 if none
 }
-`, pErr.error, pErr.Location,
-	)
+
+var _ Parsing = (*Parser)(nil)
+
+// Init prepares the parser for a file registered in a FileSet.
+// The first token is read by the first call to next.
+func (p *Parser) Init(file *utils.File, errHandler func(error), mode Mode) {
+	p.file = file
+	p.mode = mode
+	p.prevEnd = 0
+	p.errh = errHandler
+	p.errors = nil
+	p.traceOut = os.Stdout
+	p.indent = 0
+	p.blockLevel = 1
+	p.fieldLevel = 0
+
+	scanMode := uint(0)
+	if mode&KeepComments != 0 {
+		scanMode |= comments
+	}
+	p.layout.init(bytes.NewReader(file.Source()), func(r, c uint, msg string) {
+		p.report(errorOf(Location{FilePath: file.Name(), Line: r, Col: c}, "Syntax error: %s", msg))
+	}, scanMode)
 }
 
-// better way to debug?
-const debug = false
-const trace = false
+// A bailout panic stops parsing after too many errors
+type bailout struct{}
 
-// The main parser
-type Parser struct {
-	scanner
-	filePath string
+// report records an error and hands it to the error handler.
+func (p *Parser) report(err error) {
+	pErr, ok := err.(ParsingError)
+	if !ok {
+		pErr = ParsingError{Location: p.Locate(), Msg: err.Error()}
+	}
+	p.errors = append(p.errors, pErr)
+	if p.errh != nil {
+		p.errh(pErr)
+	}
+	if p.mode&AllErrors == 0 && len(p.errors) >= errorLimit {
+		panic(bailout{})
+	}
 }
 
-func NewParser(t *testing.T, in io.Reader) Parser {
-	p := Parser{}
-	p.Init(in, func(err error) {
-		t.Log(err.Error())
-	})
-	p.next() // Fill buffer
+// Tracing support, use as `defer un(trace(p, "Decl"))`
+func (p *Parser) printTrace(a ...any) {
+	const dots = ". . . . . . . . . . . . . . . . . . . . . . . . . . . . . . . . "
+	const n = len(dots)
+	loc := p.Locate()
+	fmt.Fprintf(p.traceOut, "%5d:%3d: ", loc.Line, loc.Col)
+	i := 2 * p.indent
+	for i > n {
+		fmt.Fprint(p.traceOut, dots)
+		i -= n
+	}
+	fmt.Fprint(p.traceOut, dots[0:i])
+	fmt.Fprintln(p.traceOut, a...)
+}
+
+func trace(p *Parser, msg string) *Parser {
+	if p.mode&Trace != 0 {
+		p.printTrace(msg, "(")
+		p.indent++
+	}
 	return p
 }
 
-func (p *Parser) Init(r io.Reader, errHandler func(error)) {
-	p.scanner.init(r, func(r, c uint, msg string) {
-		errHandler(fmt.Errorf("Syntax error: (%d, %d) %s", r, c, msg))
-	}, 0)
+func un(p *Parser) {
+	if p.mode&Trace != 0 {
+		p.indent--
+		p.printTrace(")")
+	}
+}
+
+// sync skips the rest of a broken declaration, stopping at the
+// ';' which ends it, at the '}' which ends the block holding it,
+// or at EOF.
+func (p *Parser) sync() {
+	for p.token.tag != _EOF &&
+		!(p.token.tag == _Semi && p.level == p.blockLevel) &&
+		!(p.token.tag == _BraceRight && p.level <= p.blockLevel && p.blockLevel > 1) {
+		p.next()
+	}
+}
+
+// badExpr skips the rest of a broken declaration and returns
+// a placeholder for the expression starting at m.
+func (p *Parser) badExpr(m mark) *ast.BadExpr {
+	p.sync()
+	bad := new(ast.BadExpr)
+	p.finish(bad, m)
+	return bad
+}
+
+// next advances to the next token, remembering where the
+// current one ends. Virtual tokens take up no source.
+func (p *Parser) next() error {
+	if p.token.end > p.token.start {
+		p.prevEnd = p.token.end
+	}
+	return p.layout.next()
+}
+
+// A mark records where the parsing of a node started
+type mark struct {
+	loc  Location
+	offs int
+}
+
+func (p *Parser) mark() mark {
+	return mark{p.Locate(), p.token.start}
+}
+
+// markOf returns the mark at the start of an already parsed node.
+func (p *Parser) markOf(n ast.Node) mark {
+	return mark{n.Locate(), p.file.Offset(n.Span().Start)}
+}
+
+// finish stamps n with the span from m up to the end of
+// the last consumed token.
+func (p *Parser) finish(n ast.Node, m mark) {
+	p.finishAt(n, m, p.prevEnd)
+}
+
+// finishAt stamps n with the span from m up to the offset end.
+func (p *Parser) finishAt(n ast.Node, m mark, end int) {
+	if end < m.offs {
+		end = m.offs
+	}
+	n.SetSpan(m.loc, utils.Span{Start: p.file.Pos(m.offs), End: p.file.Pos(end)})
 }
 
 func (p *Parser) errorOf(format string, args ...any) ParsingError {
@@ -75,305 +186,2550 @@ func (p *Parser) errorOf(format string, args ...any) ParsingError {
 }
 
 // Parsing a file
-func (p *Parser) ParseFile() (*ast.File, error) {
-	f := new(ast.File)
-	f.Location = p.Locate()
+func (p *Parser) ParseFile() (f *ast.File, err error) {
+	defer un(trace(p, "File"))
+	f = new(ast.File)
+	m := p.mark()
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(bailout); !ok {
+				panic(e)
+			}
+			// Too many errors, return what we have
+			p.finish(f, m)
+			err = p.errors.Err()
+		}
+	}()
+
+	// Module header
+	for p.token.tag == _Semi {
+		p.next()
+	}
+	if p.token.tag == _Module {
+		doc := p.docComment()
+		module, err := p.ParseModuleDecl()
+		if err != nil {
+			p.report(err)
+			p.sync()
+		}
+		if module != nil {
+			module.SetDoc(doc)
+			f.Module = module
+			f.PkgName = module.Name
+		}
+	}
 
 	// While not end of file
+	imports := true // still in the import section
 	for p.token.tag != _EOF {
-		// fmt.Println(p.token)
+		// Empty declarations
+		if p.token.tag == _Semi {
+			p.next()
+			continue
+		}
+		if p.token.tag != _Import {
+			imports = false
+		} else if !imports {
+			p.report(p.errorOf("Imports must come before all other declarations"))
+		}
+		dm := p.mark()
 		decl, err := p.ParseDecl()
 		if err != nil {
-			return nil, err
+			p.report(err)
+			if decl == nil {
+				bad := new(ast.BadDecl)
+				p.sync()
+				p.finish(bad, dm)
+				decl = bad
+			}
 		}
 		f.DeclList = append(f.DeclList, decl)
-		p.next() // seperator?
-	}
-	return f, nil
-}
 
-func (p *Parser) ParseDecl() (ast.Decl, error) {
-	if p.token.tag == _Ident {
-		fName, err := p.ParseNameExpr()
-		if err != nil {
-			return nil, err
-		}
+		// Declarations are separated by ';' or a new line
 		switch p.token.tag {
-		case _Colon:
-			return p.ParseTypeDecl(fName)
-		case _Ident, _Assign, _ParentLeft, _Integer:
-			return p.ParseFuncDecl(fName)
+		case _Semi:
+			p.next()
+		case _EOF:
 		default:
-			return nil, p.errorOf(
-				"Expected ':' | '=' | identifier, found %#v\n",
+			p.report(p.errorOf(
+				"Expected ';' or new line after declaration, found %#v\n",
 				p.token,
-			)
+			))
+			p.sync()
 		}
 	}
+	f.EOF = p.Locate()
+	p.finish(f, m)
+	f.DeclList = p.groupBindings(f.DeclList)
+	return f, p.errors.Err()
+}
+
+// Parsing a declaration together with its doc comment. On error
+// the declaration may still be returned, holding BadExpr nodes.
+func (p *Parser) ParseDecl() (ast.Decl, error) {
+	defer un(trace(p, "Decl"))
+	doc := p.docComment()
+	decl, err := p.parseDecl()
+	if decl != nil && doc != nil {
+		decl.SetDoc(doc)
+	}
+	return decl, err
+}
+
+func (p *Parser) parseDecl() (ast.Decl, error) {
+	switch p.token.tag {
+	case _Ident, _ParentLeft, _BracketLeft:
+		return p.parseBinding()
+	case _Import:
+		return declOf(p.ParseImportDecl())
+	case _Enum:
+		return declOf(p.ParseEnumDecl())
+	case _Seal:
+		return declOf(p.ParseSealDecl())
+	case _Impl:
+		return declOf(p.ParseImplDecl())
+	case _Infixl, _Infixr, _Infix:
+		return declOf(p.ParseFixityDecl())
+	case _Module:
+		return nil, p.errorOf("The module header must come first in a file")
+	}
+	if p.token.isKeyword() {
+		return nil, p.errorOf(
+			"Unexpected keyword `%s` at the start of a declaration\n",
+			p.token.lit,
+		)
+	}
 	return nil, p.errorOf(
 		"Expected identifier, found %#v\n",
 		p.token,
 	)
 }
 
-// `let x <expression>)`
-// `let (f x...) <expression>)`
-// f | x = <expression>
-func (p *Parser) ParseFuncDecl(fName *ast.Name) (*ast.FuncDecl, error) {
-	decl := new(ast.FuncDecl)
-	decl.Name = fName
-	// decl.Location =
-	// p.next()
-	switch p.token.tag {
+// declOf returns what a parsing function returns as an ast.Decl, a
+// nil declaration being nil rather than a nil *D, so that a partial
+// declaration is kept along with the error and a missing one is not.
+func declOf[P interface {
+	*D
+	ast.Decl
+}, D any](decl P, err error) (ast.Decl, error) {
+	if decl == nil {
+		return nil, err
+	}
+	return decl, err
+}
 
-	// Function w/o parameters
-	case _Assign, _Ident, _ParentLeft, _Integer:
-		args := []ast.Pattern{}
-		var err error
-		for err == nil {
-			var arg ast.Pattern
-			arg, err = p.ParsePatternExpr()
-			if err == nil {
-				args = append(args, arg)
-			}
+// A type signature or an equation, at the top level or in the body
+// of a seal:
+//
+//	f : Int -> Int
+//	(<>) : a -> a -> a
+//	f x = x
+//	x == y = not (x != y)
+func (p *Parser) parseBinding() (ast.Decl, error) {
+	if p.token.tag == _ParentLeft || p.token.tag == _BracketLeft {
+		// An infix definition with a pattern on the left, or `(<>)`
+		next, err := p.peek()
+		if err != nil {
+			return nil, err
 		}
-		decl.Params = args
-		if p.token.tag == _Assign {
-			p.next()
+		if p.token.tag == _BracketLeft || next.tag != _Symbol {
+			left, err := p.ParsePatternExpr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.atOperator() {
+				return nil, p.errorOf("Expected an operator after %v, found %#v", left, p.token)
+			}
+			decl, err := p.parseInfixFuncDecl(left)
+			return decl, err
 		}
-		body, err := p.ParseFuncCallExpr()
+	}
+	fName, err := p.parseVar()
+	if err != nil {
+		return nil, err
+	}
+	switch p.token.tag {
+	case _Colon:
+		decl, err := p.ParseTypeDecl(fName)
+		return decl, err
+	case _Ident, _Assign, _ParentLeft, _BracketLeft, _BraceLeft, _Integer, _String, _Rune:
+		decl, err := p.ParseFuncDecl(fName)
+		return decl, err
+	case _Symbol, _InfixName:
+		left := &ast.VarPattern{Name: fName}
+		p.finish(left, p.markOf(fName))
+		decl, err := p.parseInfixFuncDecl(left)
+		return decl, err
+	default:
+		return nil, p.errorOf(
+			"Expected ':' | '=' | identifier, found %#v\n",
+			p.token,
+		)
+	}
+}
+
+// module example
+// module example (fact, List(..), Category(..))
+func (p *Parser) ParseModuleDecl() (*ast.ModuleDecl, error) {
+	defer un(trace(p, "ModuleDecl"))
+	m := p.mark()
+	if p.token.tag != _Module {
+		return nil, p.errorOf("Expected `module`, found %#v", p.token)
+	}
+	p.next()
+	decl := new(ast.ModuleDecl)
+	name, err := p.parseModuleName()
+	if err != nil {
+		return nil, err
+	}
+	decl.Name = name
+
+	if p.token.tag == _ParentLeft {
+		specs, err := p.parseSpecList()
 		if err != nil {
 			return nil, err
 		}
-		decl.Body = body
-
-	default:
-		return nil, p.errorOf("Error while parsing function declaration")
+		decl.ExportList = specs
 	}
+	p.finish(decl, m)
 	return decl, nil
 }
 
-// x : Int
-// f : Int -> Int
-func (p *Parser) ParseTypeDecl(fName *ast.Name) (*ast.TypeDecl, error) {
+// import Data.Functor
+// import Data.Functor (fmap, Functor(..))
+// import Data.Functor hiding (fmap)
+// import qualified Data.Map as M
+func (p *Parser) ParseImportDecl() (*ast.ImportDecl, error) {
+	defer un(trace(p, "ImportDecl"))
+	m := p.mark()
+	if p.token.tag != _Import {
+		return nil, p.errorOf("Expected `import`, found %#v", p.token)
+	}
 	p.next()
-	t, err := p.ParseType()
+	decl := new(ast.ImportDecl)
+	if p.token.tag == _Ident && p.token.lit == "qualified" {
+		p.next()
+		decl.Qualified = true
+	}
+	path, err := p.parseModuleName()
 	if err != nil {
 		return nil, err
 	}
-	return &ast.TypeDecl{
-		Name: fName,
-		Type: t,
-	}, nil
-}
+	decl.Path = path.Value
 
-// Int
-// Int -> Int
-// (Int -> Int) -> Int
-func (p *Parser) ParseType() (ast.Type, error) {
-	switch p.token.tag {
-	case _Ident:
-		t, err := p.ParseFuncCallExpr()
+	if p.token.tag == _Ident && p.token.lit == "as" {
+		p.next()
+		alias, err := p.parseModuleName()
 		if err != nil {
 			return nil, err
 		}
-		if p.token.tag != _Arrow {
-			return t, nil
-		}
+		decl.Alias = alias
+	}
+	if p.token.tag == _Ident && p.token.lit == "hiding" {
 		p.next()
-		ts, err := p.ParseType()
+		decl.Hiding = true
+		if p.token.tag != _ParentLeft {
+			return nil, p.errorOf("Expected '(' after `hiding`, found %#v", p.token)
+		}
+	}
+	if p.token.tag == _ParentLeft {
+		items, err := p.parseSpecList()
 		if err != nil {
 			return nil, err
 		}
-		return &ast.FuncType{
-			Context: []ast.Field{},
-			Types:   []ast.Type{t, ts},
-		}, nil
+		decl.Items = items
+	}
+	p.finish(decl, m)
+	return decl, nil
+}
 
-	case _ParentLeft:
-		p.next()
-		t, err := p.ParseType()
+// `(x, T(..), (<>))`, an export or import list
+func (p *Parser) parseSpecList() ([]*ast.ExportSpec, error) {
+	if p.token.tag != _ParentLeft {
+		return nil, p.errorOf("Expected '(', found %#v", p.token)
+	}
+	p.next()
+	specs := []*ast.ExportSpec{}
+	for p.token.tag != _ParentRight {
+		spec, err := p.parseExportSpec()
 		if err != nil {
 			return nil, err
 		}
-		if p.token.tag == _ParentRight {
-			p.next()
-		}
-		if p.token.tag != _Arrow {
-			return t, nil
+		specs = append(specs, spec)
+		if p.token.tag != _Comma {
+			break
 		}
 		p.next()
-		ts, err := p.ParseType()
-		if err != nil {
-			return nil, err
-		}
-		return &ast.FuncType{
-			Context: []ast.Field{},
-			Types:   []ast.Type{t, ts},
-		}, nil
-	default:
-		return nil, p.errorOf("ParseType: Unexpected token: %#v\n", p.token)
 	}
+	if p.token.tag != _ParentRight {
+		return nil, p.errorOf("Expected ',' or ')', found %#v", p.token)
+	}
+	p.next()
+	return specs, nil
 }
 
-// `(f x...)`
-func (p *Parser) ParseExpr() (ast.Expr, error) {
-	switch p.token.tag {
-	case _Integer:
-		return p.ParseIntegerExpr()
-	case _Ident:
-		fCall := new(ast.CallExpr)
-		fName := &ast.Name{Value: p.token.lit}
-		fCall.Fun = fName
-		p.next()
-		return fCall, nil
-	case _ParentLeft:
+// fact
+// (<>)
+// List(..)
+// Person(New, OfId)
+func (p *Parser) parseExportSpec() (*ast.ExportSpec, error) {
+	m := p.mark()
+	name, err := p.parseVar()
+	if err != nil {
+		return nil, err
+	}
+	spec := &ast.ExportSpec{Name: name}
+	if p.token.tag == _ParentLeft {
 		p.next()
-		if p.token.tag == _Symbol {
-			fCall := new(ast.CallExpr)
-			name := &ast.Name{Value: p.token.lit}
-			fCall.Fun = name
+		if p.token.tag == _DotDot {
 			p.next()
-			if p.token.tag == _ParentRight {
+			spec.All = true
+		} else {
+			spec.Members = []*ast.Name{}
+			for p.token.tag != _ParentRight {
+				member, err := p.parseVar()
+				if err != nil {
+					return nil, err
+				}
+				spec.Members = append(spec.Members, member)
+				if p.token.tag != _Comma {
+					break
+				}
 				p.next()
 			}
-
-			for param, err := p.ParseExpr(); err == nil; {
-				fCall.ArgList = append(fCall.ArgList, param)
-				param, err = p.ParseExpr()
-			}
-			return fCall, nil
 		}
+		if p.token.tag != _ParentRight {
+			return nil, p.errorOf("Expected ')' after the members of %s, found %#v", name, p.token)
+		}
+		p.next()
+	}
+	p.finish(spec, m)
+	return spec, nil
+}
 
-		// Function call
-		expr, err := p.ParseFuncCallExpr()
+// `Data.Functor`, a dotted module name
+func (p *Parser) parseModuleName() (*ast.Name, error) {
+	m := p.mark()
+	first, err := p.ParseNameExpr()
+	if err != nil {
+		return nil, err
+	}
+	path := first.Value
+	for p.token.tag == _Dot {
+		p.next()
+		part, err := p.ParseNameExpr()
 		if err != nil {
 			return nil, err
 		}
-		if p.token.tag == _ParentRight {
-			p.next()
-		}
-		return expr, nil
-	default:
-		return nil, p.errorOf("ParseExpr error: encounter %#v", p.token)
+		path += "." + part.Value
 	}
+	name := &ast.Name{Value: path}
+	p.finish(name, m)
+	return name, nil
 }
 
-// `x`
-func (p *Parser) ParseNameExpr() (*ast.Name, error) {
-	switch p.token.tag {
-	case _Ident:
-		name := &ast.Name{Value: p.token.lit}
-		p.next()
-		return name, nil
-	default:
-		return nil, p.errorOf("ParseNameExpr error: encounter %#v", p.token)
+// `x` or an operator in parentheses, e.g. `(<>)`
+func (p *Parser) parseVar() (*ast.Name, error) {
+	if p.token.tag != _ParentLeft {
+		return p.ParseNameExpr()
+	}
+	m := p.mark()
+	p.next()
+	name, err := p.parseSymbol()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.tag != _ParentRight {
+		return nil, p.errorOf("Expected ')' after operator %s, found %#v", name, p.token)
 	}
+	p.next()
+	p.finish(name, m)
+	return name, nil
 }
 
-func (p *Parser) ParsePatternExpr() (ast.Pattern, error) {
+// `let x <expression>)`
+// `let (f x...) <expression>)`
+// f n | n < 2 = n | otherwise = <expression>
+//
+// One equation, the only clause of the function until the clauses
+// of its other equations are grouped with it.
+func (p *Parser) ParseFuncDecl(fName *ast.Name) (*ast.FuncDecl, error) {
+	defer un(trace(p, "FuncDecl"))
+	clause := new(ast.Clause)
+	decl := &ast.FuncDecl{Name: fName, Clauses: []*ast.Clause{clause}}
+	m := p.markOf(fName)
 	switch p.token.tag {
-	case _Ident:
-		return p.ParseNameExpr()
-	case _ParentLeft:
-		p.next()
-		pattern, err := p.ParseFuncCallExpr()
-		if err != nil {
-			return nil, err
+
+	// Function w/o parameters
+	case _Assign, _Ident, _ParentLeft, _BracketLeft, _BraceLeft, _Integer, _String, _Rune:
+		args := []ast.Pattern{}
+		for p.atPattern() {
+			arg, err := p.ParsePatternExpr()
+			if err != nil {
+				clause.Params = args
+				clause.Body = p.badExpr(p.mark())
+				p.finishEquation(decl, m)
+				return decl, err
+			}
+			args = append(args, arg)
 		}
-		if p.token.tag == _ParentRight {
-			p.next()
+		clause.Params = args
+		if p.token.tag != _Assign && p.token.tag != _Bar {
+			err := p.errorOf("Expected '=' in function declaration, found %#v", p.token)
+			clause.Body = p.badExpr(p.mark())
+			p.finishEquation(decl, m)
+			return decl, err
 		}
-		return pattern, nil
-	case _Integer:
-		return p.ParseIntegerExpr()
+		bm := p.mark()
+		body, err := p.parseRhs(_Assign)
+		if err == nil && ast.IsConName(fName.Value) && p.token.tag == _FatArrow {
+			body, err = p.parseQualifiedRhs(body, bm)
+		}
+		if err != nil {
+			clause.Body = p.badExpr(bm)
+			p.finishEquation(decl, m)
+			return decl, err
+		}
+		clause.Body = body
 
-	// case _Float:
-	// return p.Parse
 	default:
-		return nil, p.errorOf("ParsePatternExpr: %#v\n", p.token)
+		err := p.errorOf("Error while parsing function declaration")
+		clause.Body = p.badExpr(p.mark())
+		p.finishEquation(decl, m)
+		return decl, err
 	}
+	p.finishEquation(decl, m)
+	return decl, nil
 }
 
-// `7`
-func (p *Parser) ParseIntegerExpr() (*ast.Integer, error) {
-	switch p.token.tag {
-	case _Integer:
-		i, err := strconv.Atoi(p.token.lit)
+// Showable = Show a => a
+// Printable = (Show a, Eq a) => a
+//
+// The body of a type synonym, read as an expression up to '=>', turns
+// out to be the context of a qualified type.
+func (p *Parser) parseQualifiedRhs(body ast.Expr, m mark) (ast.Type, error) {
+	constraints := []ast.Expr{body}
+	if call, ok := body.(*ast.CallExpr); ok {
+		if name, ok := call.Fun.(*ast.Name); ok && name.Value == TupleName(len(call.ArgList)) {
+			constraints = call.ArgList
+		}
+	}
+	context := []ast.Field{}
+	for _, c := range constraints {
+		field, err := p.contextOf(typeOfExpr(c))
 		if err != nil {
 			return nil, err
 		}
-		j := &ast.Integer{Value: i}
-		p.next()
-		return j, nil
-	default:
-		return nil, p.errorOf("ParseIntegerExpr error: encounter %#v", p.token)
+		context = append(context, field)
+	}
+	p.next()
+	t, err := p.ParseType()
+	if err != nil {
+		return nil, err
+	}
+	fType, ok := t.(*ast.FuncType)
+	if !ok {
+		fType = &ast.FuncType{Types: []ast.Type{t}}
 	}
+	fType.Context = append(context, fType.Context...)
+	p.finish(fType, m)
+	return fType, nil
 }
 
-// `f x...`
-func (p *Parser) ParseFuncCallExpr() (*ast.CallExpr, error) {
-	if !(p.token.tag == _Ident || p.token.tag == _ParentLeft) {
-		return nil, p.errorOf("ParseFuncCallExpr error: encounter %#v", p.token)
+// typeOfExpr turns an application read as an expression into the
+// type it stands for: the variables `a` of `Show a` become `a` types.
+func typeOfExpr(e ast.Expr) ast.Type {
+	switch e := e.(type) {
+	case *ast.Name:
+		t := &ast.CallExpr{Fun: e}
+		t.SetSpan(e.Locate(), e.Span())
+		return t
+	case *ast.CallExpr:
+		t := &ast.CallExpr{Fun: e.Fun}
+		for _, arg := range e.ArgList {
+			t.ArgList = append(t.ArgList, typeOfExpr(arg))
+		}
+		t.SetSpan(e.Locate(), e.Span())
+		return t
 	}
-	fCall := new(ast.CallExpr)
-	switch p.token.tag {
-	// f x
-	case _Ident:
-		fName := &ast.Name{Value: p.token.lit}
-		fCall.Fun = fName
-		p.next()
-		// fmt.Println(p.token)
-		for param, err := p.ParseExpr(); err == nil; {
-			fCall.ArgList = append(fCall.ArgList, param)
-			param, err = p.ParseExpr()
-		}
-		// if p.token.tag != _ParentRight {
-		// 	return nil, fmt.Errorf("ParseFuncCallExpr error: encounter %#v", p.token)
-		// }
-		// p.next()
-		return fCall, nil
+	return e
+}
 
-	// case _Symbol:
+// x == y = not (x != y)
+// x `div` y = ...
+// (x :: xs) ++ ys = ...
+func (p *Parser) parseInfixFuncDecl(left ast.Pattern) (*ast.FuncDecl, error) {
+	defer un(trace(p, "InfixFuncDecl"))
+	m := p.markOf(left)
+	om := p.mark()
+	op := strings.Trim(p.token.lit, "`")
+	p.next()
+	clause := new(ast.Clause)
+	decl := &ast.FuncDecl{Name: &ast.Name{Value: op}, Clauses: []*ast.Clause{clause}}
+	p.finish(decl.Name, om)
+	right, err := p.ParsePatternExpr()
+	if err != nil {
+		clause.Body = p.badExpr(p.mark())
+		p.finishEquation(decl, m)
+		return decl, err
+	}
+	clause.Params = []ast.Pattern{left, right}
+	if p.token.tag != _Assign && p.token.tag != _Bar {
+		err := p.errorOf("Expected '=' in function declaration, found %#v", p.token)
+		clause.Body = p.badExpr(p.mark())
+		p.finishEquation(decl, m)
+		return decl, err
+	}
+	bm := p.mark()
+	body, err := p.parseRhs(_Assign)
+	if err != nil {
+		clause.Body = p.badExpr(bm)
+		p.finishEquation(decl, m)
+		return decl, err
+	}
+	clause.Body = body
+	p.finishEquation(decl, m)
+	return decl, nil
+}
 
-	// (f x) y
-	case _ParentLeft:
-		p.next()
+// finishEquation stamps a function of one equation, and the
+// equation, with the span from m.
+func (p *Parser) finishEquation(decl *ast.FuncDecl, m mark) {
+	p.finish(decl.Clauses[0], m)
+	p.finish(decl, m)
+}
 
-		// fmt.Println(">>>", p.token)
-		if p.token.tag == _Symbol {
-			// (-) 3 2
-			name := &ast.Name{Value: p.token.lit}
-			fCall.Fun = name
-			p.next()
+// infixl 6 +, -
+// infixr 5 ::
+// infix 4 `elem`
+func (p *Parser) ParseFixityDecl() (*ast.FixityDecl, error) {
+	defer un(trace(p, "FixityDecl"))
+	m := p.mark()
+	decl := new(ast.FixityDecl)
+	switch p.token.tag {
+	case _Infixl:
+		decl.Assoc = ast.InfixLeft
+	case _Infixr:
+		decl.Assoc = ast.InfixRight
+	case _Infix:
+		decl.Assoc = ast.InfixNone
+	default:
+		return nil, p.errorOf("Expected a fixity declaration, found %#v", p.token)
+	}
+	p.next()
+	decl.Prec = 9
+	if p.token.tag == _Integer {
+		prec, err := strconv.Atoi(p.token.lit)
+		if err != nil || prec > 9 {
+			return nil, p.errorOf("Precedence must be between 0 and 9, found %s", p.token.lit)
+		}
+		decl.Prec = prec
+		p.next()
+	}
+	for {
+		if !p.atOperator() {
+			return nil, p.errorOf("Expected an operator in fixity declaration, found %#v", p.token)
+		}
+		decl.Ops = append(decl.Ops, p.parseOperator())
+		if p.token.tag != _Comma {
+			break
+		}
+		p.next()
+	}
+	p.finish(decl, m)
+	return decl, nil
+}
+
+// x : Int
+// f : Int -> Int
+func (p *Parser) ParseTypeDecl(fName *ast.Name) (*ast.TypeDecl, error) {
+	defer un(trace(p, "TypeDecl"))
+	m := p.markOf(fName)
+	p.next()
+	decl := &ast.TypeDecl{Name: fName}
+	tm := p.mark()
+	t, err := p.ParseType()
+	if err != nil {
+		decl.Type = p.badExpr(tm)
+		p.finish(decl, m)
+		return decl, err
+	}
+	decl.Type = t
+	p.finish(decl, m)
+	return decl, nil
+}
+
+// enum List a { Nil : List a; (::) : a -> List a -> List a }
+// enum Person { New { id : Int, name : String }; OfId Int }
+func (p *Parser) ParseEnumDecl() (*ast.EnumDecl, error) {
+	defer un(trace(p, "EnumDecl"))
+	m := p.mark()
+	if p.token.tag != _Enum {
+		return nil, p.errorOf("Expected `enum`, found %#v", p.token)
+	}
+	p.next()
+	decl := new(ast.EnumDecl)
+	name, err := p.ParseNameExpr()
+	if err != nil {
+		return nil, err
+	}
+	decl.Name = name
+	params, err := p.parseTypeParams()
+	if err != nil {
+		return nil, err
+	}
+	decl.Params = params
+	hm := p.markOf(name)
+
+	err = p.parseBlock(func() error {
+		con, err := p.parseConDecl(decl, hm)
+		if err != nil {
+			return err
+		}
+		decl.Cons = append(decl.Cons, *con)
+		return nil
+	})
+	p.finish(decl, m)
+	return decl, err
+}
+
+// seal Monoid a { zero : a; (<>) : a -> a -> a }
+// seal Eq a { (==) : a -> a -> Bool; x == y = not (x != y) }
+// seal Semi a => Monoid a { empty : a }
+// seal (Eq a, Show a) => Ord a { ... }
+func (p *Parser) ParseSealDecl() (*ast.SealDecl, error) {
+	defer un(trace(p, "SealDecl"))
+	m := p.mark()
+	if p.token.tag != _Seal {
+		return nil, p.errorOf("Expected `seal`, found %#v", p.token)
+	}
+	p.next()
+	decl := new(ast.SealDecl)
+
+	// Superclasses
+	if p.token.tag == _ParentLeft {
+		context, err := p.parseContext()
+		if err != nil {
+			return nil, err
+		}
+		decl.Context = context
+	}
+	name, err := p.ParseNameExpr()
+	if err != nil {
+		return nil, err
+	}
+	params, err := p.parseTypeParams()
+	if err != nil {
+		return nil, err
+	}
+	if decl.Context == nil && p.token.tag == _FatArrow {
+		// What we parsed is the only superclass
+		super, err := p.constraintOf(name, params)
+		if err != nil {
+			return nil, err
+		}
+		decl.Context = []ast.Field{{Type: super}}
+		p.finish(&decl.Context[0], p.markOf(name))
+		p.next()
+		if name, err = p.ParseNameExpr(); err != nil {
+			return nil, err
+		}
+		if params, err = p.parseTypeParams(); err != nil {
+			return nil, err
+		}
+	}
+	decl.Name = name
+	decl.Params = params
+
+	err = p.parseBlock(func() error {
+		field, err := p.parseBinding()
+		if err != nil {
+			return err
+		}
+		switch field := field.(type) {
+		case *ast.TypeDecl:
+			decl.Fields = append(decl.Fields, *field)
+		case *ast.FuncDecl:
+			decl.Defaults = append(decl.Defaults, field)
+		}
+		return nil
+	})
+	decl.Defaults = p.groupClauses(decl.Defaults)
+	p.finish(decl, m)
+	return decl, err
+}
+
+// (Eq a, Show a) =>
+// (a b : Type) =>
+func (p *Parser) parseContext() ([]ast.Field, error) {
+	if p.token.tag != _ParentLeft {
+		return nil, p.errorOf("Expected '(', found %#v", p.token)
+	}
+	p.next()
+	context := []ast.Field{}
+	for p.token.tag != _ParentRight {
+		m := p.mark()
+		names := []*ast.Name{}
+		for p.token.tag == _Ident {
+			name, err := p.ParseNameExpr()
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return nil, p.errorOf("Expected a binder or a constraint, found %#v", p.token)
+		}
+		if p.token.tag == _Colon {
+			// Binders declared together share their kind
+			p.next()
+			kind, err := p.ParseType()
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range names {
+				context = append(context, ast.Field{Name: name, Type: kind})
+				p.finish(&context[len(context)-1], m)
+			}
+		} else {
+			args := []ast.Type{}
+			for _, name := range names[1:] {
+				arg := &ast.CallExpr{Fun: name}
+				p.finish(arg, p.markOf(name))
+				args = append(args, arg)
+			}
+			for p.atAType() {
+				arg, err := p.parseAType()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+			}
+			context = append(context, p.contextField(names[0], args, m))
+		}
+		if p.token.tag != _Comma {
+			break
+		}
+		p.next()
+	}
+	if p.token.tag != _ParentRight {
+		return nil, p.errorOf("Expected ')' after the context, found %#v", p.token)
+	}
+	p.next()
+	if p.token.tag != _FatArrow {
+		return nil, p.errorOf("Expected '=>' after the context, found %#v", p.token)
+	}
+	p.next()
+	return context, nil
+}
+
+// contextField makes an entry of a context out of `Eq a`, which
+// is a constraint, or out of a lone `a`, which binds a variable.
+func (p *Parser) contextField(name *ast.Name, args []ast.Type, m mark) ast.Field {
+	field := ast.Field{Name: name}
+	if len(args) > 0 {
+		t := &ast.CallExpr{Fun: name}
+		for _, arg := range args {
+			t.ArgList = append(t.ArgList, arg)
+		}
+		p.finish(t, m)
+		field = ast.Field{Type: t}
+	}
+	p.finish(&field, m)
+	return field
+}
+
+// constraintOf turns `Semi a`, parsed as the head of a seal, into
+// the type of the constraint it turned out to be.
+func (p *Parser) constraintOf(name *ast.Name, params []ast.Field) (ast.Type, error) {
+	t := &ast.CallExpr{Fun: name}
+	for i := range params {
+		if params[i].Type != nil {
+			return nil, errorOf(params[i].Locate(), "Unexpected kind annotation in a constraint")
+		}
+		arg := &ast.CallExpr{Fun: params[i].Name}
+		p.finish(arg, p.markOf(params[i].Name))
+		t.ArgList = append(t.ArgList, arg)
+	}
+	p.finish(t, p.markOf(name))
+	return t, nil
+}
+
+// impl Category (->) { ... }
+// impl (a : Type) => Semi (List a) { ... }
+// impl a => Monoid (List a) { ... }
+// impl ListFunctor : Functor List { ... }
+// impl Person = tom
+func (p *Parser) ParseImplDecl() (*ast.ImplDecl, error) {
+	defer un(trace(p, "ImplDecl"))
+	m := p.mark()
+	if p.token.tag != _Impl {
+		return nil, p.errorOf("Expected `impl`, found %#v", p.token)
+	}
+	p.next()
+	decl := new(ast.ImplDecl)
+
+	// The head, after the name and the contexts if any
+	var head *ast.Name
+	for head == nil {
+		if p.token.tag == _ParentLeft {
+			context, err := p.parseContext()
+			if err != nil {
+				return nil, err
+			}
+			decl.Context = append(decl.Context, context...)
+			continue
+		}
+		hm := p.mark()
+		name, err := p.ParseNameExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.token.tag == _Colon && decl.Name == nil && decl.Context == nil {
+			p.next()
+			decl.Name = name
+			continue
+		}
+		args := []ast.Type{}
+		for p.atAType() {
+			arg, err := p.parseAType()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		if p.token.tag == _FatArrow {
+			p.next()
+			decl.Context = append(decl.Context, p.contextField(name, args, hm))
+			continue
+		}
+		head = name
+		decl.Args = args
+	}
+	decl.Seal = head
+
+	if p.token.tag == _Assign {
+		p.next()
+		value, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		decl.Value = value
+		p.finish(decl, m)
+		return decl, nil
+	}
+	err := p.parseBlock(func() error {
+		method, err := p.parseBinding()
+		if err != nil {
+			return err
+		}
+		switch method := method.(type) {
+		case *ast.FuncDecl:
+			decl.Methods = append(decl.Methods, method)
+		default:
+			return errorOf(method.Locate(), "Only definitions of methods are allowed in an impl")
+		}
+		return nil
+	})
+	decl.Methods = p.groupClauses(decl.Methods)
+	p.finish(decl, m)
+	return decl, err
+}
+
+// a b (f : Type -> Type), the parameters of an enum or seal
+func (p *Parser) parseTypeParams() ([]ast.Field, error) {
+	params := []ast.Field{}
+	for p.token.tag == _Ident || p.token.tag == _ParentLeft {
+		m := p.mark()
+		if p.token.tag == _Ident {
+			name, err := p.ParseNameExpr()
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, ast.Field{Name: name})
+			p.finish(&params[len(params)-1], m)
+			continue
+		}
+		p.next()
+		name, err := p.ParseNameExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.token.tag != _Colon {
+			return nil, p.errorOf("Expected ':' after type parameter %s, found %#v", name, p.token)
+		}
+		p.next()
+		kind, err := p.ParseType()
+		if err != nil {
+			return nil, err
+		}
+		if p.token.tag != _ParentRight {
+			return nil, p.errorOf("Expected ')' after the kind of %s, found %#v", name, p.token)
+		}
+		p.next()
+		params = append(params, ast.Field{Name: name, Type: kind})
+		p.finish(&params[len(params)-1], m)
+	}
+	return params, nil
+}
+
+// Nil : List a
+// New { id : Int, name : String }
+// OfId Int
+//
+// The header mark hm spans the enum name and its parameters, the
+// source of the result type of the positional and record forms.
+func (p *Parser) parseConDecl(enum *ast.EnumDecl, hm mark) (*ast.TypeDecl, error) {
+	defer un(trace(p, "ConDecl"))
+	m := p.mark()
+	name, err := p.parseVar()
+	if err != nil {
+		return nil, err
+	}
+	con := &ast.TypeDecl{Name: name}
+
+	// GADT-style signature
+	if p.token.tag == _Colon {
+		p.next()
+		t, err := p.ParseType()
+		if err != nil {
+			return nil, err
+		}
+		con.Type = t
+		p.finish(con, m)
+		return con, nil
+	}
+
+	args := []ast.Type{}
+	if p.token.tag == _BraceLeft {
+		record, err := p.parseRecordType()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, record)
+	} else {
+		for p.atAType() {
+			arg, err := p.parseAType()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+	}
+
+	// Int -> Person
+	var t ast.Type = p.enumType(enum, hm)
+	if len(args) > 0 {
+		t = &ast.FuncType{
+			Context: []ast.Field{},
+			Types:   append(args, t),
+		}
+		p.finish(t, p.markOf(args[0]))
+	}
+	con.Type = t
+	p.finish(con, m)
+	return con, nil
+}
+
+// enumType builds the type `List a` declared by an enum, for
+// the constructors which leave it implicit.
+func (p *Parser) enumType(enum *ast.EnumDecl, hm mark) ast.Type {
+	t := &ast.CallExpr{Fun: &ast.Name{Value: enum.Name.Value}}
+	p.finish(t.Fun, p.markOf(enum.Name))
+	for _, param := range enum.Params {
+		arg := &ast.CallExpr{Fun: &ast.Name{Value: param.Name.Value}}
+		p.finish(arg.Fun, p.markOf(param.Name))
+		p.finish(arg, p.markOf(param.Name))
+		t.ArgList = append(t.ArgList, arg)
+	}
+	// The span of the header, not of the constructor just parsed
+	end := enum.Name.Span().End
+	if len(enum.Params) > 0 {
+		end = enum.Params[len(enum.Params)-1].Span().End
+	}
+	p.finishAt(t, hm, p.file.Offset(end))
+	return t
+}
+
+// { id : Int, name : String }
+func (p *Parser) parseRecordType() (*ast.RecordType, error) {
+	defer un(trace(p, "RecordType"))
+	m := p.mark()
+	if p.token.tag != _BraceLeft {
+		return nil, p.errorOf("Expected '{', found %#v", p.token)
+	}
+	p.next()
+	record := &ast.RecordType{Fields: []ast.Field{}}
+	for {
+		// Fields are separated by ',' or by new lines
+		for p.token.tag == _Comma || p.token.tag == _Semi {
+			p.next()
+		}
+		if p.token.tag == _BraceRight {
+			break
+		}
+		fm := p.mark()
+		name, err := p.ParseNameExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.token.tag != _Colon {
+			return nil, p.errorOf("Expected ':' after field %s, found %#v", name, p.token)
+		}
+		p.next()
+		t, err := p.ParseType()
+		if err != nil {
+			return nil, err
+		}
+		record.Fields = append(record.Fields, ast.Field{Name: name, Type: t})
+		p.finish(&record.Fields[len(record.Fields)-1], fm)
+		if !(p.token.tag == _Comma || p.token.tag == _Semi || p.token.tag == _BraceRight) {
+			return nil, p.errorOf("Expected ',' or '}' after field %s, found %#v", name, p.token)
+		}
+	}
+	p.next()
+	p.finish(record, m)
+	return record, nil
+}
+
+// parseBlock parses `{ item; item; ... }`, where the braces and
+// semicolons are either written out or inserted by the layout
+// pass. A broken item is reported and skipped, so that the rest
+// of the block is still parsed.
+func (p *Parser) parseBlock(item func() error) error {
+	if p.token.tag != _BraceLeft {
+		return p.errorOf("Expected '{', found %#v", p.token)
+	}
+	p.next()
+	defer func(outer int) { p.blockLevel = outer }(p.blockLevel)
+	p.blockLevel = p.level
+	for p.token.tag != _BraceRight && p.token.tag != _EOF {
+		if p.token.tag == _Semi {
+			p.next()
+			continue
+		}
+		err := item()
+		if err == nil && p.token.tag != _Semi && p.token.tag != _BraceRight {
+			err = p.errorOf("Expected ';' or new line, found %#v", p.token)
+		}
+		if err != nil {
+			p.report(err)
+			p.sync()
+		}
+	}
+	if p.token.tag != _BraceRight {
+		return p.errorOf("Expected '}' at the end of the block, found %#v", p.token)
+	}
+	p.next()
+	return nil
+}
+
+// List a
+// Maybe (a -> b)
+func (p *Parser) parseBType() (*ast.CallExpr, error) {
+	m := p.mark()
+	name, err := p.ParseNameExpr()
+	if err != nil {
+		return nil, err
+	}
+	t := &ast.CallExpr{Fun: name}
+	for p.atAType() {
+		arg, err := p.parseAType()
+		if err != nil {
+			return nil, err
+		}
+		t.ArgList = append(t.ArgList, arg)
+	}
+	p.finish(t, m)
+	return t, nil
+}
+
+// atAType reports whether the current token starts an atomic type.
+func (p *Parser) atAType() bool {
+	switch p.token.tag {
+	case _Ident, _ParentLeft, _BracketLeft:
+		return true
+	}
+	return false
+}
+
+// Int
+// List a
+// (a -> b)
+// (->)
+// (Int, String), a tuple
+// (), the unit
+// [a], a list
+func (p *Parser) parseAType() (ast.Type, error) {
+	switch p.token.tag {
+	case _Ident:
+		m := p.mark()
+		name, err := p.ParseNameExpr()
+		if err != nil {
+			return nil, err
+		}
+		t := &ast.CallExpr{Fun: name}
+		p.finish(t, m)
+		return t, nil
+	case _ParentLeft:
+		m := p.mark()
+		p.next()
+		if p.token.tag == _Arrow || p.token.tag == _Symbol {
+			// (->)
+			name := &ast.Name{Value: p.token.lit}
+			p.next()
+			if p.token.tag != _ParentRight {
+				return nil, p.errorOf("Expected ')' after operator %s, found %#v", name, p.token)
+			}
+			p.next()
+			p.finish(name, m)
+			return name, nil
+		}
+		t, _, err := p.parseParenType(m, false)
+		return t, err
+	case _BracketLeft:
+		m := p.mark()
+		p.next()
+		elem, err := p.ParseType()
+		if err != nil {
+			return nil, err
+		}
+		if p.token.tag != _BracketRight {
+			return nil, p.errorOf("Expected ']' after type %s, found %#v", elem, p.token)
+		}
+		p.next()
+		return p.listType(elem, m), nil
+	default:
+		return nil, p.errorOf("Expected a type, found %#v", p.token)
+	}
+}
+
+// (a, b), a tuple type
+// (a : Type), binders, or (Eq a, Show a), constraints, in a context
+//
+// The contents of the parentheses have been read as types, unless
+// they bind variables: they turn out to be a context when followed by
+// '=>', which is only looked for at the head of a type.
+func (p *Parser) parseParenType(m mark, head bool) (ast.Type, []ast.Field, error) {
+	items := []ast.Field{} // types, or binders when they have a name
+	binders := false
+	for p.token.tag != _ParentRight {
+		im := p.mark()
+		t, err := p.ParseType()
+		if err != nil {
+			return nil, nil, err
+		}
+		if p.token.tag != _Colon {
+			items = append(items, ast.Field{Type: t})
+			p.finish(&items[len(items)-1], im)
 		} else {
-			f, err := p.ParseExpr()
-			// Normal function call
+			// Binders declared together share their kind
+			names, err := p.bindersOf(t)
+			if err != nil {
+				return nil, nil, err
+			}
+			p.next()
+			kind, err := p.ParseType()
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, name := range names {
+				items = append(items, ast.Field{Name: name, Type: kind})
+				p.finish(&items[len(items)-1], im)
+			}
+			binders = true
+		}
+		if p.token.tag != _Comma {
+			break
+		}
+		p.next()
+	}
+	if p.token.tag != _ParentRight {
+		return nil, nil, p.errorOf("Expected ')' after type %s, found %#v", items[len(items)-1].Type, p.token)
+	}
+	p.next()
+
+	if head && (binders || p.token.tag == _FatArrow) {
+		context := []ast.Field{}
+		for _, item := range items {
+			if item.Name != nil {
+				context = append(context, item)
+				continue
+			}
+			field, err := p.contextOf(item.Type)
+			if err != nil {
+				return nil, nil, err
+			}
+			context = append(context, field)
+		}
+		return nil, context, nil
+	}
+	if binders {
+		return nil, nil, p.errorOf("Binders are only allowed in a context, before '=>'")
+	}
+	types := make([]ast.Type, len(items))
+	for i, item := range items {
+		types[i] = item.Type
+	}
+	if len(types) == 1 {
+		return types[0], nil, nil
+	}
+	return p.tupleType(types, m), nil, nil
+}
+
+// bindersOf returns the variables of `a b`, read as a type before
+// the ':' of their kind.
+func (p *Parser) bindersOf(t ast.Type) ([]*ast.Name, error) {
+	vars := []ast.Expr{}
+	if call, ok := t.(*ast.CallExpr); ok {
+		vars = append([]ast.Expr{call.Fun}, call.ArgList...)
+	}
+	names := []*ast.Name{}
+	for _, v := range vars {
+		if arg, ok := v.(*ast.CallExpr); ok && len(arg.ArgList) == 0 {
+			v = arg.Fun
+		}
+		name, ok := v.(*ast.Name)
+		if !ok || ast.IsConName(name.Value) {
+			break
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 || len(names) != len(vars) {
+		return nil, errorOf(t.Locate(), "Expected type variables before ':', found %v", t)
+	}
+	return names, nil
+}
+
+// contextOf turns a type read before '=>' into an entry of the
+// context: `Eq a` is a constraint and a lone `a` binds a variable.
+func (p *Parser) contextOf(t ast.Type) (ast.Field, error) {
+	call, ok := t.(*ast.CallExpr)
+	if !ok {
+		return ast.Field{}, errorOf(t.Locate(), "Expected a constraint or a type variable, found %v", t)
+	}
+	name, ok := call.Fun.(*ast.Name)
+	if !ok {
+		return ast.Field{}, errorOf(t.Locate(), "Expected a constraint or a type variable, found %v", t)
+	}
+	args := []ast.Type{}
+	for _, arg := range call.ArgList {
+		args = append(args, arg)
+	}
+	return p.contextField(name, args, p.markOf(t)), nil
+}
+
+// Int
+// Int -> Int
+// (Int -> Int) -> Int
+// [a] -> (a, b)
+// Monoid a => List a -> a
+// (a b : Type) => (a -> b) -> [a] -> [b]
+// forall a. a -> a
+//
+// The binders and constraints of a context go in the Context of the
+// FuncType, which has no parameter types when the type has no arrow.
+func (p *Parser) ParseType() (ast.Type, error) {
+	defer un(trace(p, "Type"))
+	m := p.mark()
+	context := []ast.Field{}
+	var t ast.Type
+	for t == nil {
+		var fields []ast.Field
+		var err error
+		switch p.token.tag {
+		case _Forall:
+			p.next()
+			if fields, err = p.parseTypeParams(); err != nil {
+				return nil, err
+			}
+			if p.token.tag != _Dot {
+				return nil, p.errorOf("Expected '.' after the variables of `forall`, found %#v", p.token)
+			}
+			p.next()
+			context = append(context, fields...)
+			continue
+		case _Ident:
+			t, err = p.parseBType()
+		case _ParentLeft:
+			pm := p.mark()
+			p.next()
+			t, fields, err = p.parseParenType(pm, true)
+		case _BracketLeft:
+			t, err = p.parseAType()
+		default:
+			return nil, p.errorOf("ParseType: Unexpected token: %#v\n", p.token)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t != nil && p.token.tag == _FatArrow {
+			field, err := p.contextOf(t)
+			if err != nil {
+				return nil, err
+			}
+			fields, t = []ast.Field{field}, nil
+		}
+		if t == nil {
+			if p.token.tag != _FatArrow {
+				return nil, p.errorOf("Expected '=>' after the context, found %#v", p.token)
+			}
+			p.next()
+			context = append(context, fields...)
+		}
+	}
+
+	if p.token.tag == _Arrow {
+		p.next()
+		ts, err := p.ParseType()
+		if err != nil {
+			return nil, err
+		}
+		t = ast.FlattenFuncType(&ast.FuncType{
+			Context: []ast.Field{},
+			Types:   []ast.Type{t, ts},
+		})
+		p.finish(t, m)
+	}
+	if len(context) == 0 {
+		return t, nil
+	}
+	fType, ok := t.(*ast.FuncType)
+	if !ok {
+		fType = &ast.FuncType{Types: []ast.Type{t}}
+	}
+	fType.Context = append(context, fType.Context...)
+	p.finish(fType, m)
+	return fType, nil
+}
+
+// fact (n - 1) + fact (n - 2)
+// x `div` 2
+// - x
+//
+// The operators are left unresolved in an ast.InfixExpr, since
+// their fixities may come from modules not parsed yet; see
+// ResolveOperators.
+func (p *Parser) ParseExpr() (ast.Expr, error) {
+	defer un(trace(p, "Expr"))
+	chain, op, err := p.parseInfixExpr()
+	if err != nil {
+		return nil, err
+	}
+	if op != nil {
+		return nil, p.errorOf("Expected an operand after %s, found %#v", op, p.token)
+	}
+	return chain, nil
+}
+
+// parseInfixExpr parses operands separated by operators. It stops
+// at a ')' found instead of an operand, and returns the operator
+// before it, which makes the expression the start of a left section.
+func (p *Parser) parseInfixExpr() (ast.Expr, *ast.Name, error) {
+	return p.parseInfixRest(p.mark(), nil)
+}
+
+// parseInfixRest is parseInfixExpr for a chain which starts at m,
+// and whose first operand is already parsed unless it is nil.
+func (p *Parser) parseInfixRest(m mark, first ast.Expr) (ast.Expr, *ast.Name, error) {
+	if first != nil {
+		m = p.markOf(first)
+	}
+	chain := new(ast.InfixExpr)
+	operand := first
+	for {
+		if operand == nil {
+			var err error
+			if operand, err = p.parseOperand(); err != nil {
+				return nil, nil, err
+			}
+		}
+		chain.Exprs = append(chain.Exprs, operand)
+		operand = nil
+		if !p.atOperator() {
+			break
+		}
+		op := p.parseOperator()
+		if p.token.tag == _ParentRight {
+			return p.chainOf(chain, m), op, nil
+		}
+		chain.Ops = append(chain.Ops, op)
+	}
+	return p.chainOf(chain, m), nil, nil
+}
+
+// chainOf returns the expression a chain of operands stands for,
+// that is the only operand if there are no operators to resolve.
+func (p *Parser) chainOf(chain *ast.InfixExpr, m mark) ast.Expr {
+	if len(chain.Ops) == 0 {
+		if _, ok := chain.Exprs[0].(*ast.NegExpr); !ok {
+			return chain.Exprs[0]
+		}
+	}
+	p.finish(chain, m)
+	return chain
+}
+
+// `f x`, or `- f x` at the start of an operand, or one of the
+// expressions which extend as far to the right as possible
+func (p *Parser) parseOperand() (ast.Expr, error) {
+	switch p.token.tag {
+	case _Backslash:
+		return p.parseLambda()
+	case _Let:
+		return p.parseLet()
+	case _If:
+		return p.parseIf()
+	case _Case:
+		return p.parseCase()
+	case _Do:
+		return p.parseDo()
+	}
+	if p.token.tag == _Symbol && p.token.lit == "-" {
+		m := p.mark()
+		p.next()
+		x, err := p.parseApp()
+		if err != nil {
+			return nil, err
+		}
+		neg := &ast.NegExpr{X: x}
+		p.finish(neg, m)
+		return neg, nil
+	}
+	return p.parseApp()
+}
+
+// \x y -> x
+func (p *Parser) parseLambda() (*ast.LambdaExpr, error) {
+	defer un(trace(p, "Lambda"))
+	m := p.mark()
+	p.next()
+	lambda := new(ast.LambdaExpr)
+	for p.token.tag != _Arrow {
+		param, err := p.ParsePatternExpr()
+		if err != nil {
+			return nil, err
+		}
+		lambda.Params = append(lambda.Params, param)
+	}
+	if len(lambda.Params) == 0 {
+		return nil, p.errorOf("Expected a parameter after '\\', found %#v", p.token)
+	}
+	p.next()
+	body, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	lambda.Body = body
+	p.finish(lambda, m)
+	return lambda, nil
+}
+
+// let x = a * a in x + 1
+// let { double x = x * x; x = double a } in x + 1
+func (p *Parser) parseLet() (*ast.LetExpr, error) {
+	defer un(trace(p, "Let"))
+	m := p.mark()
+	p.next()
+	decls, err := p.parseLocalDecls()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseLetIn(decls, m)
+}
+
+// The `in` part of a let, after its bindings
+func (p *Parser) parseLetIn(decls []ast.Decl, m mark) (*ast.LetExpr, error) {
+	if p.token.tag != _In {
+		return nil, p.errorOf("Expected `in` after the bindings of `let`, found %#v", p.token)
+	}
+	p.next()
+	body, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	let := &ast.LetExpr{Decls: decls, Body: body}
+	p.finish(let, m)
+	return let, nil
+}
+
+// do { ref <- Ref.new empty; let y = 1; Ref.get ref }
+func (p *Parser) parseDo() (*ast.DoExpr, error) {
+	defer un(trace(p, "Do"))
+	m := p.mark()
+	p.next()
+	do := new(ast.DoExpr)
+	err := p.parseBlock(func() error {
+		stmt, err := p.parseStmt()
+		if err != nil {
+			return err
+		}
+		do.Stmts = append(do.Stmts, stmt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(do.Stmts) == 0 {
+		return nil, errorOf(m.loc, "Expected statements in the `do` block")
+	}
+	if last := do.Stmts[len(do.Stmts)-1]; !isExprStmt(last) {
+		return nil, errorOf(last.Locate(), "The last statement of a `do` block must be an expression, found %v", last)
+	}
+	p.finish(do, m)
+	return do, nil
+}
+
+func isExprStmt(stmt ast.Stmt) bool {
+	_, ok := stmt.(*ast.ExprStmt)
+	return ok
+}
+
+// ref <- Ref.new empty
+// let y = x + 1
+// Ref.get ref
+func (p *Parser) parseStmt() (ast.Stmt, error) {
+	m := p.mark()
+	if p.token.tag == _Let {
+		p.next()
+		decls, err := p.parseLocalDecls()
+		if err != nil {
+			return nil, err
+		}
+		if p.token.tag != _In {
+			stmt := &ast.LetStmt{Decls: decls}
+			p.finish(stmt, m)
+			return stmt, nil
+		}
+		// A let expression after all
+		let, err := p.parseLetIn(decls, m)
+		if err != nil {
+			return nil, err
+		}
+		stmt := &ast.ExprStmt{X: let}
+		p.finish(stmt, m)
+		return stmt, nil
+	}
+	x, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.tag != _LeftArrow {
+		stmt := &ast.ExprStmt{X: x}
+		p.finish(stmt, m)
+		return stmt, nil
+	}
+	// What we parsed is the pattern of a bind
+	pattern, err := p.patternOf(x)
+	if err != nil {
+		return nil, err
+	}
+	p.next()
+	if x, err = p.ParseExpr(); err != nil {
+		return nil, err
+	}
+	stmt := &ast.BindStmt{Pattern: pattern, X: x}
+	p.finish(stmt, m)
+	return stmt, nil
+}
+
+// if n < 2 then n else fact (n - 1)
+func (p *Parser) parseIf() (*ast.IfExpr, error) {
+	defer un(trace(p, "If"))
+	m := p.mark()
+	p.next()
+	ifExpr := new(ast.IfExpr)
+	var err error
+	if ifExpr.Cond, err = p.ParseExpr(); err != nil {
+		return nil, err
+	}
+	if err := p.expectBranch(_Then); err != nil {
+		return nil, err
+	}
+	if ifExpr.Then, err = p.ParseExpr(); err != nil {
+		return nil, err
+	}
+	if err := p.expectBranch(_Else); err != nil {
+		return nil, err
+	}
+	if ifExpr.Else, err = p.ParseExpr(); err != nil {
+		return nil, err
+	}
+	p.finish(ifExpr, m)
+	return ifExpr, nil
+}
+
+// expectBranch skips the `then` or `else` of an if. They may start
+// a line of their own in a block, after the ';' of the layout pass.
+func (p *Parser) expectBranch(tag tokenTag) error {
+	if p.token.tag == _Semi && p.token.end == p.token.start {
+		if next, err := p.peek(); err == nil && next.tag == tag {
+			p.next()
+		}
+	}
+	if p.token.tag != tag {
+		return p.errorOf("Expected `%s` in if expression, found %#v", strings.ToLower(tag.String()), p.token)
+	}
+	p.next()
+	return nil
+}
+
+// case xs of { Nil -> ys; Cons x xs -> x :: (xs ++ ys) }
+func (p *Parser) parseCase() (*ast.CaseExpr, error) {
+	defer un(trace(p, "Case"))
+	m := p.mark()
+	p.next()
+	x, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.tag != _Of {
+		return nil, p.errorOf("Expected `of` after the scrutinee of `case`, found %#v", p.token)
+	}
+	p.next()
+	caseExpr := &ast.CaseExpr{X: x}
+	err = p.parseBlock(func() error {
+		alt, err := p.parseCaseAlt()
+		if err != nil {
+			return err
+		}
+		caseExpr.Alts = append(caseExpr.Alts, alt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.finish(caseExpr, m)
+	return caseExpr, nil
+}
+
+// Cons x xs -> x :: (xs ++ ys)
+// Just x | x > 0 -> x
+func (p *Parser) parseCaseAlt() (*ast.CaseAlt, error) {
+	m := p.mark()
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.tag != _Arrow && p.token.tag != _Bar {
+		return nil, p.errorOf("Expected '->' after the pattern of a case alternative, found %#v", p.token)
+	}
+	body, err := p.parseRhs(_Arrow)
+	if err != nil {
+		return nil, err
+	}
+	alt := &ast.CaseAlt{Pattern: pattern, Body: body}
+	p.finish(alt, m)
+	return alt, nil
+}
+
+// The right-hand side of an equation or a case alternative, an
+// expression after sep, `=` or `->`, or guarded expressions, followed
+// by an optional `where` block.
+func (p *Parser) parseRhs(sep tokenTag) (ast.Expr, error) {
+	var body ast.Expr
+	var err error
+	m := p.mark()
+	if p.token.tag == _Bar {
+		body, err = p.parseGuardedExpr(sep)
+	} else {
+		p.next() // sep
+		m = p.mark()
+		body, err = p.ParseExpr()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.token.tag != _Where {
+		return body, nil
+	}
+	p.next()
+	decls, err := p.parseLocalDecls()
+	if err != nil {
+		return nil, err
+	}
+	where := &ast.WhereExpr{Body: body, Decls: decls}
+	p.finish(where, m)
+	return where, nil
+}
+
+// | n < 2 = n | otherwise = fact (n - 1) + fact (n - 2)
+// | Just x <- lookup k m -> x
+func (p *Parser) parseGuardedExpr(sep tokenTag) (*ast.GuardedExpr, error) {
+	defer un(trace(p, "GuardedExpr"))
+	m := p.mark()
+	guarded := new(ast.GuardedExpr)
+	for p.token.tag == _Bar {
+		am := p.mark()
+		p.next()
+		alt := new(ast.GuardedAlt)
+		for {
+			guard, err := p.parseGuard()
+			if err != nil {
+				return nil, err
+			}
+			alt.Guards = append(alt.Guards, guard)
+			if p.token.tag != _Comma {
+				break
+			}
+			p.next()
+		}
+		if p.token.tag != sep {
+			lit := "="
+			if sep == _Arrow {
+				lit = "->"
+			}
+			return nil, p.errorOf("Expected ',' or '%s' after a guard, found %#v", lit, p.token)
+		}
+		p.next()
+		body, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		alt.Body = body
+		p.finish(alt, am)
+		guarded.Alts = append(guarded.Alts, alt)
+	}
+	p.finish(guarded, m)
+	return guarded, nil
+}
+
+// n < 2
+// Just x <- lookup k m
+func (p *Parser) parseGuard() (*ast.Guard, error) {
+	m := p.mark()
+	x, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	guard := &ast.Guard{X: x}
+	if p.token.tag == _LeftArrow {
+		// What we parsed is the pattern of a pattern guard
+		if guard.Pattern, err = p.patternOf(x); err != nil {
+			return nil, err
+		}
+		p.next()
+		if guard.X, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	p.finish(guard, m)
+	return guard, nil
+}
+
+// The block of a let or a where
+func (p *Parser) parseLocalDecls() ([]ast.Decl, error) {
+	decls := []ast.Decl{}
+	err := p.parseBlock(func() error {
+		decl, err := p.parseBinding()
+		if err != nil {
+			return err
+		}
+		decls = append(decls, decl)
+		return nil
+	})
+	return p.groupBindings(decls), err
+}
+
+// atOperator reports whether the current token is an infix operator.
+func (p *Parser) atOperator() bool {
+	return p.token.tag == _Symbol || p.token.tag == _InfixName || p.token.tag == _Dot
+}
+
+// `<>`, or `div` in backticks
+func (p *Parser) parseOperator() *ast.Name {
+	m := p.mark()
+	op := &ast.Name{Value: strings.Trim(p.token.lit, "`")}
+	p.next()
+	p.finish(op, m)
+	return op
+}
+
+// `f x...`, or the lone `f`
+func (p *Parser) parseApp() (ast.Expr, error) {
+	m := p.mark()
+	fun, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+	if !p.atAtom() || p.atField() {
+		return fun, nil
+	}
+	fCall := &ast.CallExpr{Fun: fun}
+	for p.atAtom() && !p.atField() {
+		arg, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		fCall.ArgList = append(fCall.ArgList, arg)
+	}
+	p.finish(fCall, m)
+	return fCall, nil
+}
+
+// atAtom reports whether the current token starts an atom.
+func (p *Parser) atAtom() bool {
+	switch p.token.tag {
+	case _Ident, _Integer, _String, _Rune, _ParentLeft, _BracketLeft:
+		return true
+	}
+	return false
+}
+
+// p.id
+// Person.New { id = 0, name = "Tom" }
+// tom { name = "Tim" }
+//
+// A '.' right after an atom, with no space between them, selects a
+// field or qualifies a name, and must be followed right away by
+// the name. A '.' after a space is the operator of composition,
+// f . g, left to the infix expression.
+func (p *Parser) parseAtom() (ast.Expr, error) {
+	m := p.mark()
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.token.tag == _Dot && p.token.start == p.prevEnd:
+			next, err := p.peek()
+			if err != nil {
+				return nil, err
+			}
+			if next.tag != _Ident || next.start != p.token.end {
+				return nil, p.errorOf("Expected a name right after '.', found %#v; composition is written f . g", next)
+			}
+			p.next()
+			sel, err := p.ParseNameExpr()
+			if err != nil {
+				return nil, err
+			}
+			selector := &ast.SelectorExpr{X: x, Sel: sel, Qualified: isQualifier(x)}
+			p.finish(selector, m)
+			x = selector
+		case p.token.tag == _BraceLeft && p.token.end > p.token.start:
+			// Only an explicit '{' starts the fields of a record
+			if x, err = p.parseRecordExpr(x, m); err != nil {
+				return nil, err
+			}
+		default:
+			return x, nil
+		}
+	}
+}
+
+// isQualifier reports whether x.name is a qualified name: x is a
+// module, seal or enum, whose names are capitalized, as in Ref.new,
+// Data.Map.empty and Person.New.
+func isQualifier(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Name:
+		return ast.IsConName(x.Value)
+	case *ast.SelectorExpr:
+		return x.Qualified && ast.IsConName(x.Sel.Value)
+	}
+	return false
+}
+
+// x
+// 7
+// "abc"
+// (f x)
+// (<>)
+// (<> x)
+// (x <>)
+func (p *Parser) parsePrimary() (ast.Expr, error) {
+	switch p.token.tag {
+	case _Integer:
+		return p.ParseIntegerExpr()
+	case _String:
+		return p.ParseStringExpr()
+	case _Rune:
+		return p.ParseRuneExpr()
+	case _Ident:
+		return p.ParseNameExpr()
+	case _ParentLeft:
+		return p.parseParenExpr()
+	case _BracketLeft:
+		return p.parseListExpr()
+	default:
+		if p.token.isKeyword() {
+			return nil, p.errorOf("Unexpected keyword `%s` in an expression", p.token.lit)
+		}
+		return nil, p.errorOf("Expected an expression, found %#v", p.token)
+	}
+}
+
+// Person.New { id = 0, name = "Tom" }, when x is a constructor
+// tom { name = "Tim" }, an update of x otherwise
+func (p *Parser) parseRecordExpr(x ast.Expr, m mark) (ast.Expr, error) {
+	defer un(trace(p, "RecordExpr"))
+	p.next()
+	defer func(outer int) { p.fieldLevel = outer }(p.fieldLevel)
+	p.fieldLevel = p.level
+	fields := []*ast.FieldValue{}
+	for {
+		// Fields are separated by ',', by new lines, or just follow
+		// each other
+		for p.token.tag == _Comma || p.token.tag == _Semi {
+			p.next()
+		}
+		if p.token.tag == _BraceRight {
+			break
+		}
+		if p.token.tag != _Ident {
+			return nil, p.errorOf("Expected a field name, found %#v", p.token)
+		}
+		fm := p.mark()
+		name, err := p.ParseNameExpr()
+		if err != nil {
+			return nil, err
+		}
+		field := &ast.FieldValue{Name: name}
+		if p.token.tag == _Assign {
+			p.next()
+			if field.Value, err = p.ParseExpr(); err != nil {
+				return nil, err
+			}
+		}
+		p.finish(field, fm)
+		fields = append(fields, field)
+		if !(p.token.tag == _Comma || p.token.tag == _Semi || p.token.tag == _BraceRight || p.token.tag == _Ident) {
+			return nil, p.errorOf("Expected ',' or '}' after field %s, found %#v", name, p.token)
+		}
+	}
+	p.next()
+
+	con := x
+	if selector, ok := x.(*ast.SelectorExpr); ok {
+		con = selector.Sel
+	}
+	if name, ok := con.(*ast.Name); ok && ast.IsConName(name.Value) {
+		record := &ast.RecordExpr{Con: x, Fields: fields}
+		p.finish(record, m)
+		return record, nil
+	}
+	update := &ast.UpdateExpr{X: x, Fields: fields}
+	p.finish(update, m)
+	return update, nil
+}
+
+// atField reports whether the current token starts the next field,
+// `name =`, of the record being parsed. It may follow the value of
+// the previous field without a separator.
+func (p *Parser) atField() bool {
+	if p.token.tag != _Ident || p.level != p.fieldLevel {
+		return false
+	}
+	next, err := p.peek()
+	return err == nil && next.tag == _Assign
+}
+
+// (f x)
+// (<>)
+// (<> x), (`div` 2)
+// (x <>), (1 + x <>)
+// (- x), a negation rather than a section
+// (x, y), a tuple
+// (), the unit
+func (p *Parser) parseParenExpr() (ast.Expr, error) {
+	m := p.mark()
+	p.next()
+	if p.token.tag == _ParentRight {
+		p.next()
+		return p.tupleExpr(nil, m), nil
+	}
+
+	var first ast.Expr // the negation, if the operator was `-`
+	if p.atOperator() {
+		om := p.mark()
+		infixName := p.token.tag == _InfixName
+		op := p.parseOperator()
+		switch {
+		case p.token.tag == _ParentRight && !infixName:
+			p.next()
+			return op, nil
+		case op.Value == "-" && !infixName:
+			x, err := p.parseApp()
+			if err != nil {
+				return nil, err
+			}
+			first = &ast.NegExpr{X: x}
+			p.finish(first, om)
+		default:
+			right, err := p.ParseExpr()
 			if err != nil {
-				return nil, fmt.Errorf("Error of parser: %v\n", err)
+				return nil, err
 			}
-			fCall.Fun = f
+			if err := p.expectParentRight(); err != nil {
+				return nil, err
+			}
+			section := &ast.SectionExpr{Op: op, Right: right}
+			p.finish(section, m)
+			return section, nil
 		}
+	}
 
-		if p.token.tag == _ParentRight {
+	x, op, err := p.parseInfixRest(p.mark(), first)
+	if err != nil {
+		return nil, err
+	}
+	if p.token.tag == _Comma && op == nil {
+		elems := []ast.Expr{x}
+		for p.token.tag == _Comma {
 			p.next()
+			elem, err := p.ParseExpr()
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, elem)
 		}
-		for param, err := p.ParseExpr(); err == nil; {
-			fCall.ArgList = append(fCall.ArgList, param)
-			param, err = p.ParseExpr()
+		if err := p.expectParentRight(); err != nil {
+			return nil, err
 		}
-		return fCall, nil
+		return p.tupleExpr(elems, m), nil
+	}
+	if err := p.expectParentRight(); err != nil {
+		return nil, err
+	}
+	if op != nil {
+		section := &ast.SectionExpr{Left: x, Op: op}
+		p.finish(section, m)
+		return section, nil
+	}
+	return x, nil
+}
+
+// [x, y, z]
+// []
+func (p *Parser) parseListExpr() (ast.Expr, error) {
+	m := p.mark()
+	p.next()
+	elems := []ast.Expr{}
+	for p.token.tag != _BracketRight {
+		elem, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+		if p.token.tag != _Comma {
+			break
+		}
+		p.next()
+	}
+	if p.token.tag != _BracketRight {
+		return nil, p.errorOf("Expected ',' or ']' in a list, found %#v", p.token)
+	}
+	p.next()
+	return p.listExpr(elems, m), nil
+}
+
+func (p *Parser) expectParentRight() error {
+	if p.token.tag != _ParentRight {
+		return p.errorOf("Expected ')', found %#v", p.token)
+	}
+	p.next()
+	return nil
+}
+
+// `x`
+func (p *Parser) ParseNameExpr() (*ast.Name, error) {
+	switch p.token.tag {
+	case _Ident:
+		m := p.mark()
+		name := &ast.Name{Value: p.token.lit}
+		p.next()
+		p.finish(name, m)
+		return name, nil
 	default:
-		return nil, p.errorOf("ParseFuncCallExpr error: encounter %#v", p.token)
+		if p.token.isKeyword() {
+			return nil, p.errorOf("Keyword `%s` cannot be used as an identifier", p.token.lit)
+		}
+		return nil, p.errorOf("ParseNameExpr error: encounter %#v", p.token)
 	}
 }
 
-func (p *Parser) Locate() Location {
-	return Location{
-		FilePath: p.filePath,
-		Line:     p.line,
-		Col:      p.col,
+// `(-)`, the operator in parentheses
+func (p *Parser) parseSymbol() (*ast.Name, error) {
+	if p.token.tag != _Symbol {
+		return nil, p.errorOf("parseSymbol error: encounter %#v", p.token)
+	}
+	m := p.mark()
+	name := &ast.Name{Value: p.token.lit}
+	p.next()
+	p.finish(name, m)
+	return name, nil
+}
+
+// ParsePatternExpr parses an atomic pattern, as taken by equations
+// and lambdas: constructors with arguments must be in parentheses.
+//
+//	x, _, 0, Nothing, xs@(x :: _), (a, b), [a, b], { id = i }, (x : Int)
+func (p *Parser) ParsePatternExpr() (ast.Pattern, error) {
+	defer un(trace(p, "PatternExpr"))
+	m := p.mark()
+	switch p.token.tag {
+	case _Ident:
+		name, err := p.ParseNameExpr()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case name.Value == "_":
+			wildcard := new(ast.WildcardPattern)
+			p.finish(wildcard, m)
+			return wildcard, nil
+		case ast.IsConName(name.Value) && p.token.tag == _BraceLeft:
+			return p.parseRecordPattern(name, m)
+		case ast.IsConName(name.Value):
+			con := &ast.ConPattern{Con: name}
+			p.finish(con, m)
+			return con, nil
+		case p.token.tag == _Symbol && p.token.lit == "@":
+			p.next()
+			sub, err := p.ParsePatternExpr()
+			if err != nil {
+				return nil, err
+			}
+			as := &ast.AsPattern{Name: name, Pattern: sub}
+			p.finish(as, m)
+			return as, nil
+		}
+		v := &ast.VarPattern{Name: name}
+		p.finish(v, m)
+		return v, nil
+
+	case _Integer, _String, _Rune:
+		return p.parseLitPattern(m, false)
+
+	case _ParentLeft:
+		// (), (p) or (p, q, ...)
+		p.next()
+		elems, err := p.parsePatternList(_ParentRight)
+		if err != nil {
+			return nil, err
+		}
+		if len(elems) == 1 {
+			p.finish(elems[0], m) // the span includes the parentheses
+			return elems[0], nil
+		}
+		return p.tuplePattern(elems, m), nil
+
+	case _BracketLeft:
+		p.next()
+		elems, err := p.parsePatternList(_BracketRight)
+		if err != nil {
+			return nil, err
+		}
+		return p.listPattern(elems, m), nil
+
+	case _BraceLeft:
+		return p.parseRecordPattern(nil, m)
+
+	default:
+		return nil, p.errorOf("Expected a pattern, found %#v", p.token)
+	}
+}
+
+// ParsePattern parses a full pattern, where constructors may
+// be applied to arguments without parentheses:
+//
+//	Just x
+//	x :: xs
+//	-1
+func (p *Parser) ParsePattern() (ast.Pattern, error) {
+	defer un(trace(p, "Pattern"))
+	m := p.mark()
+	chain := new(ast.InfixPattern)
+	for {
+		operand, err := p.parseLPattern()
+		if err != nil {
+			return nil, err
+		}
+		chain.Patterns = append(chain.Patterns, operand)
+		if !p.atOperator() {
+			break
+		}
+		chain.Ops = append(chain.Ops, p.parseOperator())
+	}
+	if len(chain.Ops) == 0 {
+		return chain.Patterns[0], nil
+	}
+	p.finish(chain, m)
+	return chain, nil
+}
+
+// Just x, -1, or an atomic pattern
+func (p *Parser) parseLPattern() (ast.Pattern, error) {
+	m := p.mark()
+	if p.token.tag == _Symbol && p.token.lit == "-" {
+		p.next()
+		if p.token.tag != _Integer {
+			return nil, p.errorOf("Expected a number after '-' in a pattern, found %#v", p.token)
+		}
+		return p.parseLitPattern(m, true)
+	}
+	if p.token.tag != _Ident || !ast.IsConName(p.token.lit) {
+		return p.ParsePatternExpr()
+	}
+	con, err := p.ParseNameExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.tag == _BraceLeft {
+		return p.parseRecordPattern(con, m)
+	}
+	pattern := &ast.ConPattern{Con: con}
+	for p.atPattern() {
+		arg, err := p.ParsePatternExpr()
+		if err != nil {
+			return nil, err
+		}
+		pattern.Args = append(pattern.Args, arg)
+	}
+	p.finish(pattern, m)
+	return pattern, nil
+}
+
+// 0, "abc", 'a', or -1 when negative
+func (p *Parser) parseLitPattern(m mark, negative bool) (*ast.LitPattern, error) {
+	var value ast.Expr
+	var err error
+	switch p.token.tag {
+	case _Integer:
+		var i *ast.Integer
+		if i, err = p.ParseIntegerExpr(); err == nil {
+			if negative {
+				i.Value = -i.Value
+				p.finish(i, m)
+			}
+			value = i
+		}
+	case _String:
+		value, err = p.ParseStringExpr()
+	case _Rune:
+		value, err = p.ParseRuneExpr()
+	default:
+		err = p.errorOf("Expected a literal, found %#v", p.token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	lit := &ast.LitPattern{Value: value}
+	p.finish(lit, m)
+	return lit, nil
+}
+
+// The patterns of a tuple or a list, up to the closing token
+func (p *Parser) parsePatternList(closing tokenTag) ([]ast.Pattern, error) {
+	elems := []ast.Pattern{}
+	for p.token.tag != closing {
+		m := p.mark()
+		elem, err := p.ParsePattern()
+		if err != nil {
+			return nil, err
+		}
+		if p.token.tag == _Colon {
+			// x : Int
+			p.next()
+			t, err := p.ParseType()
+			if err != nil {
+				return nil, err
+			}
+			elem = &ast.TypedPattern{Pattern: elem, Type: t}
+			p.finish(elem, m)
+		}
+		elems = append(elems, elem)
+		if p.token.tag != _Comma {
+			break
+		}
+		p.next()
+	}
+	if p.token.tag != closing {
+		return nil, p.errorOf("Expected ',' or %s in a pattern, found %#v", closing, p.token)
+	}
+	p.next()
+	return elems, nil
+}
+
+// New { id = i, name }
+// { id = i }
+func (p *Parser) parseRecordPattern(con *ast.Name, m mark) (*ast.RecordPattern, error) {
+	if p.token.tag != _BraceLeft {
+		return nil, p.errorOf("Expected '{', found %#v", p.token)
+	}
+	p.next()
+	record := &ast.RecordPattern{Con: con, Fields: []*ast.FieldPattern{}}
+	for {
+		// Fields are separated by ',' or by new lines
+		for p.token.tag == _Comma || p.token.tag == _Semi {
+			p.next()
+		}
+		if p.token.tag == _BraceRight {
+			break
+		}
+		fm := p.mark()
+		name, err := p.ParseNameExpr()
+		if err != nil {
+			return nil, err
+		}
+		field := &ast.FieldPattern{Name: name}
+		if p.token.tag == _Assign {
+			p.next()
+			if field.Pattern, err = p.ParsePattern(); err != nil {
+				return nil, err
+			}
+		}
+		p.finish(field, fm)
+		record.Fields = append(record.Fields, field)
+		if !(p.token.tag == _Comma || p.token.tag == _Semi || p.token.tag == _BraceRight) {
+			return nil, p.errorOf("Expected ',' or '}' after field %s, found %#v", name, p.token)
+		}
+	}
+	p.next()
+	p.finish(record, m)
+	return record, nil
+}
+
+// patternOf turns an expression, parsed before finding out that it
+// is the pattern of a pattern guard or of a bind, into that pattern.
+func (p *Parser) patternOf(x ast.Expr) (ast.Pattern, error) {
+	var pattern ast.Pattern
+	switch e := x.(type) {
+	case *ast.Name:
+		switch {
+		case e.Value == "_":
+			pattern = new(ast.WildcardPattern)
+		case ast.IsConName(e.Value):
+			pattern = &ast.ConPattern{Con: e}
+		default:
+			pattern = &ast.VarPattern{Name: e}
+		}
+
+	case *ast.Integer, *ast.String, *ast.Rune:
+		pattern = &ast.LitPattern{Value: e}
+
+	case *ast.NegExpr:
+		if i, ok := e.X.(*ast.Integer); ok {
+			neg := &ast.Integer{Value: -i.Value}
+			neg.SetSpan(e.Locate(), e.Span())
+			pattern = &ast.LitPattern{Value: neg}
+		}
+
+	case *ast.CallExpr:
+		con, ok := e.Fun.(*ast.Name)
+		if !ok || !ast.IsConName(con.Value) {
+			return nil, errorOf(e.Fun.Locate(), "Expected a constructor in a pattern, found %v", e.Fun)
+		}
+		args := []ast.Pattern{}
+		for _, arg := range e.ArgList {
+			pattern, err := p.patternOf(arg)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, pattern)
+		}
+		pattern = &ast.ConPattern{Con: con, Args: args}
+
+	case *ast.RecordExpr:
+		con, ok := e.Con.(*ast.Name)
+		if selector, qualified := e.Con.(*ast.SelectorExpr); qualified {
+			con, ok = selector.Sel, true
+		}
+		if !ok {
+			return nil, errorOf(e.Con.Locate(), "Expected a constructor in a pattern, found %v", e.Con)
+		}
+		record := &ast.RecordPattern{Con: con, Fields: []*ast.FieldPattern{}}
+		for _, field := range e.Fields {
+			fp := &ast.FieldPattern{Name: field.Name}
+			fp.SetSpan(field.Locate(), field.Span())
+			if field.Value != nil {
+				var err error
+				if fp.Pattern, err = p.patternOf(field.Value); err != nil {
+					return nil, err
+				}
+			}
+			record.Fields = append(record.Fields, fp)
+		}
+		pattern = record
+
+	case *ast.InfixExpr:
+		chain := new(ast.InfixPattern)
+		for i, operand := range e.Exprs {
+			pattern, err := p.patternOf(operand)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				chain.Patterns = append(chain.Patterns, pattern)
+				continue
+			}
+			op := e.Ops[i-1]
+			last := len(chain.Patterns) - 1
+			if op.Value == "@" {
+				// `x@p` binds tighter than any operator
+				v, ok := chain.Patterns[last].(*ast.VarPattern)
+				if !ok {
+					return nil, errorOf(op.Locate(), "Expected a variable before '@', found %v", chain.Patterns[last])
+				}
+				as := &ast.AsPattern{Name: v.Name, Pattern: pattern}
+				as.SetSpan(v.Locate(), utils.Span{Start: v.Span().Start, End: pattern.Span().End})
+				chain.Patterns[last] = as
+				continue
+			}
+			if !ast.IsConName(op.Value) {
+				return nil, errorOf(op.Locate(), "Expected a constructor in a pattern, found %s", op)
+			}
+			chain.Ops = append(chain.Ops, op)
+			chain.Patterns = append(chain.Patterns, pattern)
+		}
+		if len(chain.Ops) == 0 {
+			return chain.Patterns[0], nil
+		}
+		pattern = chain
+	}
+	if pattern == nil {
+		return nil, errorOf(x.Locate(), "Expected a pattern before '<-', found %v", x)
+	}
+	pattern.SetSpan(x.Locate(), x.Span())
+	return pattern, nil
+}
+
+// atPattern reports whether the current token starts an atomic pattern.
+func (p *Parser) atPattern() bool {
+	switch p.token.tag {
+	case _Ident, _Integer, _String, _Rune, _ParentLeft, _BracketLeft, _BraceLeft:
+		return true
+	}
+	return false
+}
+
+// `7`
+func (p *Parser) ParseIntegerExpr() (*ast.Integer, error) {
+	switch p.token.tag {
+	case _Integer:
+		i, err := strconv.Atoi(p.token.lit)
+		if err != nil {
+			return nil, err
+		}
+		m := p.mark()
+		j := &ast.Integer{Value: i}
+		p.next()
+		p.finish(j, m)
+		return j, nil
+	default:
+		return nil, p.errorOf("ParseIntegerExpr error: encounter %#v", p.token)
+	}
+}
+
+// `"abc"`
+// `"""raw"""`
+func (p *Parser) ParseStringExpr() (*ast.String, error) {
+	switch p.token.tag {
+	case _String:
+		lit := p.token.lit
+		var value string
+		if len(lit) >= 6 && strings.HasPrefix(lit, `"""`) && strings.HasSuffix(lit, `"""`) {
+			value = lit[3 : len(lit)-3]
+		} else {
+			var err error
+			value, err = strconv.Unquote(lit)
+			if err != nil {
+				// Already reported by the scanner
+				value = strings.Trim(lit, `"`)
+			}
+		}
+		m := p.mark()
+		str := &ast.String{Value: value}
+		p.next()
+		p.finish(str, m)
+		return str, nil
+	default:
+		return nil, p.errorOf("ParseStringExpr error: encounter %#v", p.token)
+	}
+}
+
+// `'a'`
+func (p *Parser) ParseRuneExpr() (*ast.Rune, error) {
+	switch p.token.tag {
+	case _Rune:
+		lit := p.token.lit
+		r := utf8.RuneError
+		if len(lit) >= 3 {
+			if v, _, tail, err := strconv.UnquoteChar(lit[1:len(lit)-1], '\''); err == nil && tail == "" {
+				r = v
+			}
+		}
+		// Otherwise already reported by the scanner
+		m := p.mark()
+		j := &ast.Rune{Value: r}
+		p.next()
+		p.finish(j, m)
+		return j, nil
+	default:
+		return nil, p.errorOf("ParseRuneExpr error: encounter %#v", p.token)
+	}
+}
+
+// `f x...`
+func (p *Parser) ParseFuncCallExpr() (*ast.CallExpr, error) {
+	defer un(trace(p, "FuncCallExpr"))
+	m := p.mark()
+	app, err := p.parseApp()
+	if err != nil {
+		return nil, err
+	}
+	if fCall, ok := app.(*ast.CallExpr); ok {
+		return fCall, nil
+	}
+	fCall := &ast.CallExpr{Fun: app}
+	p.finish(fCall, m)
+	return fCall, nil
+}
+
+// docComment returns the doc comment right before the current
+// token, that is a `-- |` line followed by any number of `--`
+// lines, or a `{-| -}` block. It returns nil if there is none.
+func (p *Parser) docComment() *ast.Comment {
+	lines := []string(nil)
+	for _, c := range p.comments {
+		text, isDoc := commentText(c.lit)
+		switch {
+		case isDoc:
+			lines = []string{text}
+		case lines != nil && strings.HasPrefix(c.lit, "--"):
+			lines = append(lines, text)
+		default:
+			lines = nil
+		}
 	}
+	if lines == nil {
+		return nil
+	}
+	return &ast.Comment{Text: strings.Join(lines, "\n")}
+}
+
+// commentText strips the comment markers off a comment and
+// reports whether it is a doc comment.
+func commentText(lit string) (text string, isDoc bool) {
+	if strings.HasPrefix(lit, "{-") {
+		text = strings.TrimSuffix(strings.TrimPrefix(lit, "{-"), "-}")
+	} else {
+		text = strings.TrimLeft(lit, "-")
+	}
+	if trimmed := strings.TrimLeft(text, " \t"); strings.HasPrefix(trimmed, "|") {
+		return strings.TrimSpace(trimmed[1:]), true
+	}
+	return strings.TrimSpace(text), false
 }
 
-// map : (a b : Type) => (a -> b) -> [a] -> [b]
+// Locate returns the location of the current token. Virtual
+// tokens are located right after the preceding source token.
+func (p *Parser) Locate() Location {
+	offs := p.token.start
+	if p.token.end == p.token.start && p.token.tag != _EOF {
+		offs = p.prevEnd
+	}
+	return p.file.Location(offs)
+}
@@ -2,25 +2,35 @@ package syntax
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"strings"
 	"testing"
+
+	"github.com/seal-script/sealing/ast"
+	"github.com/seal-script/sealing/utils"
 )
 
-// func NewParser(t *testing.T, in io.Reader) Parser {
-// 	p := Parser{}
-// 	p.Init(in, func(err error) {
-// 		t.Log(err.Error())
-// 	})
-// 	p.next()
-// 	return p
-// }
+// newParser builds a parser for tests, logging every error
+func newParser(t *testing.T, in io.Reader, mode Mode) Parser {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Parser{}
+	p.Init(utils.NewFileSet().AddFile(t.Name(), src), func(err error) {
+		t.Log(err.Error())
+	}, mode)
+	p.next() // Fill buffer
+	return p
+}
 
 // func TestParser(t *testing.T) {
 // 	// Slice reader
 // 	data := []byte(`(let x 测试gdfh 烤红薯烤豆腐)`)
 // 	var in io.Reader = bytes.NewReader(data)
 
-// 	p := NewParser(t, in)
+// 	p := newParser(t, in, 0)
 // 	ast, err := p.ParseFile()
 // 	if err != nil {
 // 		t.Error(err)
@@ -33,7 +43,7 @@ import (
 // 	data := []byte(`let x 7)`)
 // 	var in io.Reader = bytes.NewReader(data)
 
-// 	p := NewParser(t, in)
+// 	p := newParser(t, in, 0)
 // 	p.next()
 // 	res, err := p.ParseFuncDecl()
 // 	if err != nil {
@@ -51,7 +61,7 @@ func TestParseFuncCallExpr(t *testing.T) {
 	data := []byte(`fact n = (*) n (fact ((-) n 1))`)
 	var in io.Reader = bytes.NewReader(data)
 
-	p := NewParser(t, in)
+	p := newParser(t, in, 0)
 	// p.next()
 	res, err := p.ParseDecl()
 	if err != nil {
@@ -70,7 +80,7 @@ func TestParseType(t *testing.T) {
 	data := []byte(`map : (a -> b) -> f a -> f b`)
 	var in io.Reader = bytes.NewReader(data)
 
-	p := NewParser(t, in)
+	p := newParser(t, in, 0)
 	// p.next()
 	res, err := p.ParseDecl()
 	if err != nil {
@@ -80,3 +90,1079 @@ func TestParseType(t *testing.T) {
 	t.Log("-------------------------\n")
 	t.Logf("%v", res)
 }
+
+func TestParseLiterals(t *testing.T) {
+	data := []byte(`main = print "Hello, world!\n" '\x41' """raw\n"""`)
+	var in io.Reader = bytes.NewReader(data)
+
+	p := newParser(t, in, 0)
+	res, err := p.ParseDecl()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	args := res.(*ast.FuncDecl).Clauses[0].Body.(*ast.CallExpr).ArgList
+	if s, ok := args[0].(*ast.String); !ok || s.Value != "Hello, world!\n" {
+		t.Errorf("Expected string literal, found %v", args[0])
+	}
+	if r, ok := args[1].(*ast.Rune); !ok || r.Value != 'A' {
+		t.Errorf("Expected rune literal, found %v", args[1])
+	}
+	if s, ok := args[2].(*ast.String); !ok || s.Value != `raw\n` {
+		t.Errorf("Expected raw string literal, found %v", args[2])
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	data := []byte(`
+-- | The factorial function,
+-- defined recursively.
+fact : Int -> Int
+
+-- not a doc comment
+fact n = n
+
+{-| Doubles a number -}
+double x = x
+`)
+	p := newParser(t, bytes.NewReader(data), KeepComments)
+	file, err := p.ParseFile()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// The doc of a signature documents its function
+	docs := []string{"The factorial function,\ndefined recursively.", "Doubles a number"}
+	if len(file.DeclList) != len(docs) {
+		t.Fatalf("Expected %d declarations, found %d", len(docs), len(file.DeclList))
+	}
+	for i, decl := range file.DeclList {
+		doc := ""
+		if decl.Doc() != nil {
+			doc = decl.Doc().Text
+		}
+		if doc != docs[i] {
+			t.Errorf("Expected doc %q of declaration %d, found %q", docs[i], i, doc)
+		}
+	}
+}
+
+func TestKeywordAsIdentifier(t *testing.T) {
+	for _, src := range []string{`of = 1`, `f case = 1`, `x : where`} {
+		p := newParser(t, bytes.NewReader([]byte(src)), 0)
+		if _, err := p.ParseFile(); err == nil {
+			t.Errorf("Expected an error parsing %q", src)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	src := "f : Int -> Int\n\nfact n =\n    (*) n (fact 1)\n"
+	p := newParser(t, bytes.NewReader([]byte(src)), 0)
+	file, err := p.ParseFile()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	text := func(n ast.Node) string {
+		span := n.Span()
+		return src[p.file.Offset(span.Start):p.file.Offset(span.End)]
+	}
+
+	typeDecl := file.DeclList[0].(*ast.TypeDecl)
+	funcDecl := file.DeclList[1].(*ast.FuncDecl)
+	body := funcDecl.Clauses[0].Body.(*ast.CallExpr)
+	cases := []struct {
+		node ast.Node
+		text string
+		line uint
+		col  uint
+	}{
+		{typeDecl, "f : Int -> Int", 1, 1},
+		{typeDecl.Type, "Int -> Int", 1, 5},
+		{funcDecl, "fact n =\n    (*) n (fact 1)", 3, 1},
+		{funcDecl.Clauses[0], "fact n =\n    (*) n (fact 1)", 3, 1},
+		{funcDecl.Clauses[0].Params[0], "n", 3, 6},
+		{funcDecl.Clauses[0].Params[0].(*ast.VarPattern).Name, "n", 3, 6},
+		{body.Fun, "*", 4, 6},
+		{body.ArgList[0], "n", 4, 9},
+	}
+	for _, c := range cases {
+		if got := text(c.node); got != c.text {
+			t.Errorf("Expected span of %q, found %q", c.text, got)
+		}
+		loc := c.node.Locate()
+		if loc.FilePath != t.Name() || loc.Line != c.line || loc.Col != c.col {
+			t.Errorf("Expected %q at %d:%d, found %v", c.text, c.line, c.col, loc)
+		}
+	}
+	if text(file) != src[:len(src)-1] {
+		t.Errorf("Expected the file to span the whole source, found %q", text(file))
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	data := []byte(`
+f : Int -> Int
+g x = of
+  still broken
+h = add 1 2
+) junk
+i : ->
+j x y
+k = f 1 "unterminated
+`)
+	src, _ := io.ReadAll(bytes.NewReader(data))
+	found := 0
+	p := Parser{}
+	p.Init(utils.NewFileSet().AddFile("recover.seal", src), func(err error) {
+		found++
+	}, AllErrors)
+	p.next()
+	file, err := p.ParseFile()
+
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected an ErrorList, found %#v", err)
+	}
+	if len(errs) != found {
+		t.Errorf("Expected the handler to see all %d errors, saw %d", len(errs), found)
+	}
+	lines := []uint{}
+	for _, e := range errs {
+		lines = append(lines, e.Location.Line)
+	}
+	want := []uint{3, 6, 7, 8, 9}
+	if fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Errorf("Expected errors on lines %v, found %v\n%v", want, lines, errs)
+	}
+
+	kinds := []string{}
+	for _, decl := range file.DeclList {
+		switch d := decl.(type) {
+		case *ast.BadDecl:
+			kinds = append(kinds, "bad")
+		case *ast.FuncDecl:
+			if _, ok := d.Clauses[0].Body.(*ast.BadExpr); ok {
+				kinds = append(kinds, d.Name.Value+"=bad")
+			} else {
+				kinds = append(kinds, d.Name.Value)
+			}
+		case *ast.TypeDecl:
+			if _, ok := d.Type.(*ast.BadExpr); ok {
+				kinds = append(kinds, d.Name.Value+":bad")
+			} else {
+				kinds = append(kinds, d.Name.Value+":")
+			}
+		}
+	}
+	wantKinds := "[f: g=bad h bad i:bad j=bad k]"
+	if fmt.Sprint(kinds) != wantKinds {
+		t.Errorf("Expected declarations %s, found %v", wantKinds, kinds)
+	}
+}
+
+func TestParseFileAPI(t *testing.T) {
+	fset := utils.NewFileSet()
+	src := "-- | Identity\nid x = x\n\nbroken = )\n"
+
+	file, err := ParseFile(fset, "api.seal", src, KeepComments)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if len(file.DeclList) != 2 {
+		t.Fatalf("Expected the partial file to hold 2 declarations, found %d", len(file.DeclList))
+	}
+	if doc := file.DeclList[0].Doc(); doc == nil || doc.Text != "Identity" {
+		t.Errorf("Expected a doc comment, found %v", doc)
+	}
+	if loc := fset.Location(file.DeclList[1].Span().Start); loc != (Location{FilePath: "api.seal", Line: 4, Col: 1}) {
+		t.Errorf("Expected the broken declaration at api.seal:4:1, found %v", loc)
+	}
+
+	// Without KeepComments doc comments are dropped
+	file, _ = ParseFile(fset, "nodoc.seal", []byte(src), 0)
+	if doc := file.DeclList[0].Doc(); doc != nil {
+		t.Errorf("Expected no doc comment, found %v", doc)
+	}
+}
+
+func TestErrorLimit(t *testing.T) {
+	src := bytes.Repeat([]byte("x = )\n"), 25)
+
+	_, err := ParseFile(utils.NewFileSet(), "limit.seal", src, 0)
+	if errs, ok := err.(ErrorList); !ok || len(errs) != errorLimit {
+		t.Errorf("Expected %d errors, found %v", errorLimit, err)
+	}
+	_, err = ParseFile(utils.NewFileSet(), "all.seal", bytes.NewReader(src), AllErrors)
+	if errs, ok := err.(ErrorList); !ok || len(errs) != 25 {
+		t.Errorf("Expected 25 errors, found %v", err)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	p := newParser(t, bytes.NewReader([]byte("f x = g x")), Trace)
+	var out bytes.Buffer
+	p.traceOut = &out
+	if _, err := p.ParseFile(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte(". . FuncDecl (")) {
+		t.Errorf("Expected a trace of the function declaration, found\n%s", out.String())
+	}
+}
+
+func TestModuleHeader(t *testing.T) {
+	src := `
+module Data.Example (
+    fact,
+    (<>),
+    List(..),
+    Person(New, OfId),
+)
+
+fact n = n
+`
+	file, err := ParseFile(utils.NewFileSet(), "module.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.PkgName == nil || file.PkgName.Value != "Data.Example" {
+		t.Fatalf("Expected module Data.Example, found %v", file.PkgName)
+	}
+	specs := []string{}
+	for _, spec := range file.Module.ExportList {
+		s := spec.Name.Value
+		if spec.All {
+			s += "(..)"
+		} else if spec.Members != nil {
+			s += fmt.Sprint(spec.Members)
+		}
+		specs = append(specs, s)
+	}
+	if got, want := fmt.Sprint(specs), "[fact <> List(..) Person[New OfId]]"; got != want {
+		t.Errorf("Expected exports %s, found %s", want, got)
+	}
+	if len(file.DeclList) != 1 {
+		t.Errorf("Expected 1 declaration, found %d", len(file.DeclList))
+	}
+
+	// The header must come first
+	_, err = ParseFile(utils.NewFileSet(), "late.seal", "x = y\nmodule late", 0)
+	if err == nil {
+		t.Errorf("Expected an error for a misplaced module header")
+	}
+}
+
+func TestImports(t *testing.T) {
+	src := `
+module Main
+
+import Data.Functor
+import Data.Functor (fmap, Functor(..))
+import Data.List hiding (map)
+import qualified Data.Map as M
+
+main = fmap f xs
+`
+	file, err := ParseFile(utils.NewFileSet(), "imports.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports := []string{}
+	for _, decl := range file.DeclList {
+		imp, ok := decl.(*ast.ImportDecl)
+		if !ok {
+			continue
+		}
+		s := imp.Path
+		if imp.Qualified {
+			s = "qualified " + s
+		}
+		if imp.Alias != nil {
+			s += " as " + imp.Alias.Value
+		}
+		if imp.Hiding {
+			s += " hiding"
+		}
+		for _, item := range imp.Items {
+			s += " " + item.Name.Value
+			if item.All {
+				s += "(..)"
+			}
+		}
+		imports = append(imports, s)
+	}
+	want := "[Data.Functor Data.Functor fmap Functor(..) Data.List hiding map qualified Data.Map as M]"
+	if got := fmt.Sprint(imports); got != want {
+		t.Errorf("Expected imports %s, found %s", want, got)
+	}
+	if n := len(file.DeclList); n != 5 {
+		t.Errorf("Expected 5 declarations, found %d", n)
+	}
+
+	// Imports must come first
+	_, err = ParseFile(utils.NewFileSet(), "late.seal", "x = y\nimport Data.List", 0)
+	if err == nil {
+		t.Errorf("Expected an error for a misplaced import")
+	}
+}
+
+func TestEnumDecl(t *testing.T) {
+	src := `
+enum List a {
+    Nil  : List a
+    (::) : a -> List a -> List a
+}
+
+enum Person {
+    New { id : Int, name : String }
+    OfId Int
+}
+
+enum Vec (a : Type) n { Nil : Vec a Zero; Cons a (Vec a n) }
+enum Unit {}
+`
+	file, err := ParseFile(utils.NewFileSet(), "enum.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Nil : (List [a])", ":: : (-> [a (List [a]) (List [a])])"},
+		{"New : (-> [{id : Int, name : String} Person])", "OfId : (-> [Int Person])"},
+		{"Nil : (Vec [a Zero])", "Cons : (-> [a (Vec [a n]) (Vec [a n])])"},
+		{},
+	}
+	if len(file.DeclList) != len(want) {
+		t.Fatalf("Expected %d declarations, found %d", len(want), len(file.DeclList))
+	}
+	for i, decl := range file.DeclList {
+		enum, ok := decl.(*ast.EnumDecl)
+		if !ok {
+			t.Errorf("Expected an enum, found %v", decl)
+			continue
+		}
+		cons := []string{}
+		for _, con := range enum.Cons {
+			cons = append(cons, fmt.Sprintf("%s : %s", con.Name, con.Type))
+		}
+		if got := fmt.Sprint(cons); got != fmt.Sprint(want[i]) {
+			t.Errorf("enum %s: expected constructors %v, found %v", enum.Name, want[i], got)
+		}
+	}
+
+	vec := file.DeclList[2].(*ast.EnumDecl)
+	if len(vec.Params) != 2 || vec.Params[0].Type == nil || vec.Params[1].Type != nil {
+		t.Errorf("Expected parameters (a : Type) n, found %v", vec.Params)
+	}
+	person := file.DeclList[1].(*ast.EnumDecl)
+	record := person.Cons[0].Type.(*ast.FuncType).Types[0].(*ast.RecordType)
+	if loc := record.Fields[1].Locate(); loc.Line != 8 || loc.Col != 21 {
+		t.Errorf("Expected field name at 8:21, found %d:%d", loc.Line, loc.Col)
+	}
+
+	// A broken constructor does not hide the others
+	file, err = ParseFile(utils.NewFileSet(), "bad.seal", "enum Bad { X : ; Y Int }\nenum Ok { Z }", AllErrors)
+	if errs, ok := err.(ErrorList); !ok || len(errs) != 1 {
+		t.Fatalf("Expected one error, found %v", err)
+	}
+	if n := len(file.DeclList[0].(*ast.EnumDecl).Cons); n != 1 {
+		t.Errorf("Expected the constructor Y to be kept, found %d constructors", n)
+	}
+	if n := len(file.DeclList[1].(*ast.EnumDecl).Cons); n != 1 {
+		t.Errorf("Expected the enum Ok to be parsed, found %d constructors", n)
+	}
+}
+
+func TestSealDecl(t *testing.T) {
+	src := `
+seal Eq a {
+    (==) : a -> a -> Bool
+    x == y = not (neq x y)
+
+    (!=) : a -> a -> Bool
+    x != y = not (eq x y)
+}
+
+seal Semi a => Monoid a { empty : a }
+seal (Eq a, Show a) => Ord a { compare : a -> a -> Ordering }
+seal Category (c : Type -> Type -> Type) {
+    id : c a a
+    (~) : c a b -> c b c -> c a c
+}
+`
+	file, err := ParseFile(utils.NewFileSet(), "seal.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type seal struct {
+		context, head, fields, defaults string
+	}
+	want := []seal{
+		{"[]", "Eq [a]", "[== !=]", "[== !=]"},
+		{"[(Semi [a])]", "Monoid [a]", "[empty]", "[]"},
+		{"[(Eq [a]) (Show [a])]", "Ord [a]", "[compare]", "[]"},
+		{"[]", "Category [c]", "[id ~]", "[]"},
+	}
+	if len(file.DeclList) != len(want) {
+		t.Fatalf("Expected %d declarations, found %d", len(want), len(file.DeclList))
+	}
+	for i, decl := range file.DeclList {
+		d, ok := decl.(*ast.SealDecl)
+		if !ok {
+			t.Errorf("Expected a seal, found %v", decl)
+			continue
+		}
+		var got seal
+		context := []string{}
+		for _, c := range d.Context {
+			context = append(context, fmt.Sprint(c.Type))
+		}
+		params := []string{}
+		for _, param := range d.Params {
+			params = append(params, param.Name.Value)
+		}
+		fields := []string{}
+		for _, field := range d.Fields {
+			fields = append(fields, field.Name.Value)
+		}
+		defaults := []string{}
+		for _, def := range d.Defaults {
+			defaults = append(defaults, def.Name.Value)
+			if len(def.Clauses[0].Params) != 2 {
+				t.Errorf("Expected 2 parameters for %s, found %v", def.Name, def.Clauses[0].Params)
+			}
+		}
+		got.context = fmt.Sprint(context)
+		got.head = fmt.Sprintf("%s %v", d.Name, params)
+		got.fields = fmt.Sprint(fields)
+		got.defaults = fmt.Sprint(defaults)
+		if got != want[i] {
+			t.Errorf("Expected %+v, found %+v", want[i], got)
+		}
+	}
+	if kind := file.DeclList[3].(*ast.SealDecl).Params[0].Type; kind == nil {
+		t.Errorf("Expected the kind of c to be kept")
+	}
+}
+
+func TestImplDecl(t *testing.T) {
+	src := `
+impl Category (->) {
+    id = identity
+    (~) = (>>)
+}
+impl (a : Type) => Semi (List a) {
+    xs <> ys = append xs ys
+}
+impl a => Monoid (List a) { empty = Nil }
+impl (Eq a, Show a) => Show (Tree a) { show t = render t }
+impl ListFunctor : Functor List {
+    map f xs = mapList f xs
+}
+impl Person = tom
+`
+	file, err := ParseFile(utils.NewFileSet(), "impl.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"<nil> [] Category [->] [id ~] <nil>",
+		"<nil> [a:Type] Semi [(List [a])] [<>] <nil>",
+		"<nil> [a] Monoid [(List [a])] [empty] <nil>",
+		"<nil> [(Eq [a]) (Show [a])] Show [(Tree [a])] [show] <nil>",
+		"ListFunctor [] Functor [List] [map] <nil>",
+		"<nil> [] Person [] [] tom",
+	}
+	if len(file.DeclList) != len(want) {
+		t.Fatalf("Expected %d declarations, found %d", len(want), len(file.DeclList))
+	}
+	for i, decl := range file.DeclList {
+		d, ok := decl.(*ast.ImplDecl)
+		if !ok {
+			t.Errorf("Expected an impl, found %v", decl)
+			continue
+		}
+		context := []string{}
+		for _, field := range d.Context {
+			switch {
+			case field.Name == nil:
+				context = append(context, fmt.Sprint(field.Type))
+			case field.Type == nil:
+				context = append(context, field.Name.Value)
+			default:
+				context = append(context, fmt.Sprintf("%s:%s", field.Name, field.Type))
+			}
+		}
+		methods := []string{}
+		for _, method := range d.Methods {
+			methods = append(methods, method.Name.Value)
+		}
+		got := fmt.Sprintf("%v %v %s %v %v %v", d.Name, context, d.Seal, d.Args, methods, d.Value)
+		if got != want[i] {
+			t.Errorf("Expected %s, found %s", want[i], got)
+		}
+	}
+
+	// Instances only define methods
+	_, err = ParseFile(utils.NewFileSet(), "sig.seal", "impl Eq Int { (==) : Int -> Int -> Bool }", 0)
+	if err == nil {
+		t.Errorf("Expected an error for a signature in an impl")
+	}
+}
+
+func TestCoreExpressions(t *testing.T) {
+	src := `
+f a = x + 1 where
+    x = a * a
+
+g a = x + 1
+  where x = a * a
+
+h a = let double x = x * x
+          x = double a
+      in x + 1
+
+k a = let y = 1 in y
+
+fact n = if n < 2 then n else fact (n - 1) + fact (n - 2)
+
+xs ++ ys = case xs of
+    Nil -> ys
+    Cons x xs -> x :: (xs ++ ys)
+
+sum xs = for xs $ \x -> x + 1
+
+m x = case x of { Just y -> y; Nothing -> 0 }
+
+n x = let
+    y = x
+    in if y
+    then 1
+    else 2
+
+p x = r where
+    r = if x
+    then 1
+    else 2
+`
+	want := []string{
+		"((+ [x 1]) where 1 decls)",
+		"((+ [x 1]) where 1 decls)",
+		"(let 2 decls in (+ [x 1]))",
+		"(let 1 decls in y)",
+		"(if (< [n 2]) then n else (+ [(fact [(- [n 1])]) (fact [(- [n 2])])]))",
+		"(case xs of {Nil -> ys; (Cons x xs) -> (:: [x (++ [xs ys])])})",
+		"($ [(for [xs]) (\\[x] -> (+ [x 1]))])",
+		"(case x of {(Just y) -> y; Nothing -> 0})",
+		"(let 1 decls in (if y then 1 else 2))",
+		"(r where 1 decls)",
+	}
+	file, err := ParseFile(utils.NewFileSet(), "core.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResolveOperators(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.DeclList) != len(want) {
+		t.Fatalf("Expected %d declarations, found %d", len(want), len(file.DeclList))
+	}
+	for i, decl := range file.DeclList {
+		body := decl.(*ast.FuncDecl).Clauses[0].Body
+		if got := fmt.Sprint(body); got != want[i] {
+			t.Errorf("Expected %s, found %s", want[i], got)
+		}
+	}
+
+	// The bindings of a where block are local to it
+	where := file.DeclList[1].(*ast.FuncDecl).Clauses[0].Body.(*ast.WhereExpr)
+	if x := where.Decls[0].(*ast.FuncDecl); x.Name.Value != "x" || fmt.Sprint(x.Clauses[0].Body) != "(* [a a])" {
+		t.Errorf("Expected x = a * a, found %v", x)
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	cases := []struct {
+		src      string // an alternative of `case v of`
+		pattern  string
+		bindings string
+	}{
+		{"x -> 0", "x", "[x]"},
+		{"_ -> 0", "_", "[]"},
+		{"0 -> 0", "0", "[]"},
+		{"-1 -> 0", "-1", "[]"},
+		{`"abc" -> 0`, `"abc"`, "[]"},
+		{"'a' -> 0", "'a'", "[]"},
+		{"Nothing -> 0", "Nothing", "[]"},
+		{"Just x -> 0", "(Just x)", "[x]"},
+		{"Pair (Just x) _ -> 0", "(Pair (Just x) _)", "[x]"},
+		{"xs@(x :: _) -> 0", "xs@(x :: _)", "[xs x]"},
+		{"x :: y :: xs -> 0", "(x :: (y :: xs))", "[x y xs]"},
+		{"Just x :: xs -> 0", "((Just x) :: xs)", "[x xs]"},
+		{"(a, b) -> 0", "(Tuple2 a b)", "[a b]"},
+		{"() -> 0", "Unit", "[]"},
+		{"[] -> 0", "Nil", "[]"},
+		{"[a, _, c] -> 0", "(a :: (_ :: (c :: Nil)))", "[a c]"},
+		{"New { id = i, name } -> 0", "New {id = i, name}", "[i name]"},
+	}
+	for _, c := range cases {
+		src := "f v = case v of\n    " + c.src + "\n"
+		file, err := ParseFile(utils.NewFileSet(), "pattern.seal", src, 0)
+		if err != nil {
+			t.Errorf("%s: %v", c.src, err)
+			continue
+		}
+		if err := ResolveOperators(file, nil); err != nil {
+			t.Errorf("%s: %v", c.src, err)
+			continue
+		}
+		pattern := file.DeclList[0].(*ast.FuncDecl).Clauses[0].Body.(*ast.CaseExpr).Alts[0].Pattern
+		if got := fmt.Sprint(pattern); got != c.pattern {
+			t.Errorf("Expected pattern %s, found %s", c.pattern, got)
+		}
+		if got := fmt.Sprint(pattern.Bindings()); got != c.bindings {
+			t.Errorf("Expected %s to bind %s, found %s", c.pattern, c.bindings, got)
+		}
+	}
+
+	// Equations and lambdas take atomic patterns
+	src := `
+zip (x :: xs) (y :: ys) = Pair x y :: zip xs ys
+(x, _) <> [] = x
+id { id = i } = i
+swap = \(a, b) -> Pair b a
+`
+	file, err := ParseFile(utils.NewFileSet(), "params.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResolveOperators(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"[(x :: xs) (y :: ys)]", "[(Tuple2 x _) Nil]", "[{id = i}]"}
+	for i, params := range want {
+		if got := fmt.Sprint(file.DeclList[i].(*ast.FuncDecl).Clauses[0].Params); got != params {
+			t.Errorf("Expected parameters %s, found %s", params, got)
+		}
+	}
+	lambda := file.DeclList[3].(*ast.FuncDecl).Clauses[0].Body.(*ast.LambdaExpr)
+	if got := fmt.Sprint(lambda.Params); got != "[(Tuple2 a b)]" {
+		t.Errorf("Expected parameters [(Tuple2 a b)], found %s", got)
+	}
+}
+
+func TestGuards(t *testing.T) {
+	src := `
+fact n
+  | n < 2     = n
+  | otherwise = fact (n - 1) + fact (n - 2)
+
+lookupOr k m d
+  | Just x <- lookup k m, x > 0 = x
+lookupOr _ _ d = d
+
+sign x = case x of
+    0 -> 0
+    n | n > 0 -> 1
+      | otherwise -> -1
+
+f x | x > 0 = y
+    | otherwise = 0
+  where y = x
+
+g xs | ys@(y :: _) <- xs, -1 <- y = ys
+`
+	want := []string{
+		"(| (< [n 2]) = n | otherwise = (+ [(fact [(- [n 1])]) (fact [(- [n 2])])]))",
+		"(| (Just x) <- (lookup [k m]), (> [x 0]) = x)",
+		"(case x of {0 -> 0; n -> (| (> [n 0]) = 1 | otherwise = (negate [1]))})",
+		"((| (> [x 0]) = y | otherwise = 0) where 1 decls)",
+		"(| ys@(y :: _) <- xs, -1 <- y = ys)",
+	}
+	file, err := ParseFile(utils.NewFileSet(), "guards.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResolveOperators(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.DeclList) != len(want) {
+		t.Fatalf("Expected %d declarations, found %d", len(want), len(file.DeclList))
+	}
+	for i, decl := range file.DeclList {
+		body := decl.(*ast.FuncDecl).Clauses[0].Body
+		if got := fmt.Sprint(body); got != want[i] {
+			t.Errorf("Expected %s, found %s", want[i], got)
+		}
+	}
+	if clauses := file.DeclList[1].(*ast.FuncDecl).Clauses; len(clauses) != 2 {
+		t.Errorf("Expected lookupOr to fall through to a second clause, found %d clauses", len(clauses))
+	}
+
+	errors := []struct {
+		src, err string
+	}{
+		{"f x | g y <- x = 1", "guards.seal:1:7: Expected a constructor in a pattern, found g"},
+		{"f x | y + 1 <- x = 1", "guards.seal:1:9: Expected a constructor in a pattern, found +"},
+		{"f x | x > 0 -> 1", "guards.seal:1:13: Expected ',' or '=' after a guard"},
+	}
+	for _, c := range errors {
+		_, err := ParseFile(utils.NewFileSet(), "guards.seal", c.src, 0)
+		if err == nil {
+			t.Errorf("Expected an error for %q", c.src)
+		} else if got := err.Error(); !strings.HasPrefix(got, c.err) {
+			t.Errorf("Expected %q, found %q", c.err, got)
+		}
+	}
+}
+
+func TestDoNotation(t *testing.T) {
+	src := `
+sum xs = run $ do
+    ref <- new empty
+    for xs $ \x ->
+        set ref (<> x)
+    get ref
+
+main = do
+    let greeting = "Hello"
+        name = "Tom"
+    (x :: _) <- getArgs
+    print greeting
+    let y = 1 in print y
+
+one = do { x <- get; put x; pure x }
+`
+	want := []string{
+		"($ [run (do {ref <- (new [empty]); ($ [(for [xs]) (\\[x] -> (set [ref (<> x)]))]); (get [ref])})])",
+		"(do {let 2 decls; (x :: _) <- getArgs; (print [greeting]); (let 1 decls in (print [y]))})",
+		"(do {x <- get; (put [x]); (pure [x])})",
+	}
+	file, err := ParseFile(utils.NewFileSet(), "do.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResolveOperators(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.DeclList) != len(want) {
+		t.Fatalf("Expected %d declarations, found %d", len(want), len(file.DeclList))
+	}
+	for i, decl := range file.DeclList {
+		body := decl.(*ast.FuncDecl).Clauses[0].Body
+		if got := fmt.Sprint(body); got != want[i] {
+			t.Errorf("Expected %s, found %s", want[i], got)
+		}
+	}
+
+	errors := []struct {
+		src, err string
+	}{
+		{"f = do { x <- get }", "do.seal:1:10: The last statement of a `do` block must be an expression, found x <- get"},
+		{"f = do {}", "do.seal:1:5: Expected statements in the `do` block"},
+		{"f = do { g x <- get; pure x }", "do.seal:1:10: Expected a constructor in a pattern, found g"},
+	}
+	for _, c := range errors {
+		_, err := ParseFile(utils.NewFileSet(), "do.seal", c.src, 0)
+		if err == nil {
+			t.Errorf("Expected an error for %q", c.src)
+		} else if got := err.Error(); got != c.err {
+			t.Errorf("Expected %q, found %q", c.err, got)
+		}
+	}
+}
+
+func TestRecords(t *testing.T) {
+	src := `
+tom = Person.New { id = 0  name = "Tom" }
+jim = New {
+    id = f 2
+    name = g "Jim"
+    age
+  }
+show p = printf "(Person %d %s)" p.id p.name
+reset person = Ref.set person.id (const 0)
+rename p = p { name = "x" ++ "y" }
+deep r x = f r.a.b { c = 1 } x
+empty = Data.Map.empty
+port config = config.port
+compose f g = f . g .h
+`
+	want := []string{
+		"Person.New {id = 0, name = \"Tom\"}",
+		"New {id = (f [2]), name = (g [\"Jim\"]), age}",
+		"(printf [\"(Person %d %s)\" p.id p.name])",
+		"(Ref.set [person.id (const [0])])",
+		"(p {name = (++ [\"x\" \"y\"])})",
+		"(f [(r.a.b {c = 1}) x])",
+		"Data.Map.empty",
+		"config.port",
+		"(. [f (. [g h])])",
+	}
+	file, err := ParseFile(utils.NewFileSet(), "records.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResolveOperators(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.DeclList) != len(want) {
+		t.Fatalf("Expected %d declarations, found %d", len(want), len(file.DeclList))
+	}
+	for i, decl := range file.DeclList {
+		body := decl.(*ast.FuncDecl).Clauses[0].Body
+		if got := fmt.Sprint(body); got != want[i] {
+			t.Errorf("Expected %s, found %s", want[i], got)
+		}
+	}
+
+	// Only the names of modules, seals and enums qualify
+	var selectors []string
+	ast.Inspect(file, func(node ast.Node) bool {
+		if s, ok := node.(*ast.SelectorExpr); ok {
+			selectors = append(selectors, fmt.Sprintf("%v %v", s, s.Qualified))
+		}
+		return true
+	})
+	wantSelectors := "[Person.New true p.id false p.name false Ref.set true person.id false " +
+		"r.a.b false r.a false Data.Map.empty true Data.Map true config.port false]"
+	if got := fmt.Sprint(selectors); got != wantSelectors {
+		t.Errorf("Expected the selectors %s, found %s", wantSelectors, got)
+	}
+
+	errors := []struct {
+		src, err string
+	}{
+		{"p = New { id = 0 ) }", "records.seal:1:18: Expected ',' or '}' after field id, found"},
+		{"h = f. g", "records.seal:1:6: Expected a name right after '.', found"},
+		{"h = p.(id)", "records.seal:1:6: Expected a name right after '.', found"},
+		{"p = New { 0 }", "records.seal:1:11: Expected a field name, found"},
+	}
+	for _, c := range errors {
+		_, err := ParseFile(utils.NewFileSet(), "records.seal", c.src, 0)
+		if err == nil {
+			t.Errorf("Expected an error for %q", c.src)
+		} else if got := err.Error(); !strings.HasPrefix(got, c.err) {
+			t.Errorf("Expected %q, found %q", c.err, got)
+		}
+	}
+}
+
+func TestListsAndTuples(t *testing.T) {
+	src := `
+xs = [1, 2 + 3, f x]
+none = []
+pair = (x, y + 1)
+triple = (a, (b, c), [])
+unit = ()
+sum [] = empty
+[] ++ ys = ys
+swap (a, b) = (b, a)
+main : IO ()
+zip : [a] -> [b] -> [(a, b)]
+`
+	want := []string{
+		"(:: [1 (:: [(+ [2 3]) (:: [(f [x]) Nil])])])",
+		"Nil",
+		"(Tuple2 [x (+ [y 1])])",
+		"(Tuple3 [a (Tuple2 [b c]) Nil])",
+		"Unit",
+		"[Nil] = empty",
+		"[Nil ys] = ys",
+		"[(Tuple2 a b)] = (Tuple2 [b a])",
+		"(IO [Unit])",
+		"(-> [(List [a]) (List [b]) (List [(Tuple2 [a b])])])",
+	}
+	fset := utils.NewFileSet()
+	file, err := ParseFile(fset, "lists.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResolveOperators(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.DeclList) != len(want) {
+		t.Fatalf("Expected %d declarations, found %d", len(want), len(file.DeclList))
+	}
+	for i, decl := range file.DeclList {
+		got := ""
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			clause := d.Clauses[0]
+			got = fmt.Sprint(clause.Body)
+			if len(clause.Params) > 0 {
+				got = fmt.Sprintf("%v = %v", clause.Params, clause.Body)
+			}
+		case *ast.TypeDecl:
+			got = fmt.Sprint(d.Type)
+		}
+		if got != want[i] {
+			t.Errorf("Expected %s, found %s", want[i], got)
+		}
+	}
+
+	// The constructors made up span the brackets
+	list := file.DeclList[0].(*ast.FuncDecl).Clauses[0].Body.(*ast.CallExpr)
+	if loc := list.Fun.Locate(); loc.Line != 2 || loc.Col != 6 {
+		t.Errorf("Expected (::) at 2:6, found %v", loc)
+	}
+	if loc, end := list.Locate(), fset.Location(list.Span().End); loc.Col != 6 || end.Col != 21 {
+		t.Errorf("Expected the list to span from 2:6 to 2:21, found %v to %v", loc, end)
+	}
+
+	errors := []struct {
+		src, err string
+	}{
+		{"xs = [1, 2", "lists.seal:1:11: Expected ',' or ']' in a list, found"},
+		{"p = (1, 2 3", "lists.seal:1:12: Expected ')', found"},
+		{"f : [Int", "lists.seal:1:9: Expected ']' after type Int, found"},
+	}
+	for _, c := range errors {
+		_, err := ParseFile(utils.NewFileSet(), "lists.seal", c.src, 0)
+		if err == nil {
+			t.Errorf("Expected an error for %q", c.src)
+		} else if got := err.Error(); !strings.HasPrefix(got, c.err) {
+			t.Errorf("Expected %q, found %q", c.err, got)
+		}
+	}
+}
+
+func TestTypeContexts(t *testing.T) {
+	src := `
+map : (a b : Type) => (a -> b) -> [a] -> [b]
+sum : Monoid a => List a -> a
+(++) : a => List a -> List a -> List a
+show2 : (Show a, Show b) => a -> b -> String
+id : forall (a : Type) b. a -> a
+pure : forall f. Applicative f => a -> f a
+lift : (m : Type -> Type) => Monad m => m a
+apply : (Show a => a) -> String
+Showable = Show a => a
+Printable = (Show a, Eq a) => a
+double (x : Int) = x * x
+`
+	want := []string{
+		"(a : Type, b : Type => -> [(-> [a b]) (List [a]) (List [b])])",
+		"((Monoid [a]) => -> [(List [a]) a])",
+		"(a => -> [(List [a]) (List [a]) (List [a])])",
+		"((Show [a]), (Show [b]) => -> [a b String])",
+		"(a : Type, b => -> [a a])",
+		"(f, (Applicative [f]) => -> [a (f [a])])",
+		"(m : (-> [Type Type]), (Monad [m]) => -> [(m [a])])",
+		"(-> [((Show [a]) => -> [a]) String])",
+		"((Show [a]) => -> [a])",
+		"((Show [a]), (Eq [a]) => -> [a])",
+		"[(x : Int)] = (* [x x])",
+	}
+	file, err := ParseFile(utils.NewFileSet(), "contexts.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResolveOperators(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.DeclList) != len(want) {
+		t.Fatalf("Expected %d declarations, found %d", len(want), len(file.DeclList))
+	}
+	for i, decl := range file.DeclList {
+		got := ""
+		switch d := decl.(type) {
+		case *ast.TypeDecl:
+			got = fmt.Sprint(d.Type)
+		case *ast.FuncDecl:
+			clause := d.Clauses[0]
+			got = fmt.Sprint(clause.Body)
+			if len(clause.Params) > 0 {
+				got = fmt.Sprintf("%v = %v", clause.Params, clause.Body)
+			}
+		}
+		if got != want[i] {
+			t.Errorf("Expected %s, found %s", want[i], got)
+		}
+	}
+
+	// Named parameters bind their variable
+	double := file.DeclList[10].(*ast.FuncDecl).Clauses[0].Params[0]
+	if got := fmt.Sprint(double.Bindings()); got != "[x]" {
+		t.Errorf("Expected (x : Int) to bind [x], found %s", got)
+	}
+
+	errors := []struct {
+		src, err string
+	}{
+		{"f : (a : Type) -> a", "contexts.seal:1:16: Expected '=>' after the context, found"},
+		{"f : (Int -> Int) => Int", "contexts.seal:1:6: Expected a constraint or a type variable, found (-> [Int Int])"},
+		{"f : (A b : Type) => b", "contexts.seal:1:6: Expected type variables before ':', found (A [b])"},
+		{"f : forall a -> a", "contexts.seal:1:14: Expected '.' after the variables of `forall`, found"},
+		{"f : Maybe (a : Type)", "contexts.seal:1:21: Binders are only allowed in a context, before '=>'"},
+	}
+	for _, c := range errors {
+		_, err := ParseFile(utils.NewFileSet(), "contexts.seal", c.src, 0)
+		if err == nil {
+			t.Errorf("Expected an error for %q", c.src)
+		} else if got := err.Error(); !strings.HasPrefix(got, c.err) {
+			t.Errorf("Expected %q, found %q", c.err, got)
+		}
+	}
+}
+
+func TestLocalErrorRecovery(t *testing.T) {
+	src := `
+f a = x where
+    x = )
+    y = 1
+g = 2
+`
+	file, err := ParseFile(utils.NewFileSet(), "local.seal", src, AllErrors)
+	if errs, ok := err.(ErrorList); !ok || len(errs) != 1 || errs[0].Location.Line != 3 {
+		t.Fatalf("Expected one error on line 3, found %v", err)
+	}
+	if len(file.DeclList) != 2 {
+		t.Fatalf("Expected 2 declarations, found %d", len(file.DeclList))
+	}
+	where := file.DeclList[0].(*ast.FuncDecl).Clauses[0].Body.(*ast.WhereExpr)
+	if len(where.Decls) != 1 || where.Decls[0].(*ast.FuncDecl).Name.Value != "y" {
+		t.Errorf("Expected the binding of y to be kept, found %v", where.Decls)
+	}
+}
+
+func TestPartialDecl(t *testing.T) {
+	// A declaration broken after its head is kept
+	for _, src := range []string{"enum Shape a\ng = 2", "seal Show a\ng = 2", "impl Show Int\ng = 2"} {
+		file, err := ParseFile(utils.NewFileSet(), "partial.seal", src, AllErrors)
+		if errs, ok := err.(ErrorList); !ok || len(errs) != 1 || !strings.HasPrefix(errs[0].Msg, "Expected '{'") {
+			t.Errorf("%q: expected an error about '{', found %v", src, err)
+			continue
+		}
+		if len(file.DeclList) != 2 {
+			t.Errorf("%q: expected 2 declarations, found %v", src, file.DeclList)
+			continue
+		}
+		switch d := file.DeclList[0].(type) {
+		case *ast.EnumDecl:
+			if d.Name.Value != "Shape" || len(d.Params) != 1 {
+				t.Errorf("%q: expected the enum Shape a, found %v", src, d)
+			}
+		case *ast.SealDecl:
+			if d.Name.Value != "Show" {
+				t.Errorf("%q: expected the seal Show, found %v", src, d)
+			}
+		case *ast.ImplDecl:
+			if d.Seal.Value != "Show" {
+				t.Errorf("%q: expected an impl of Show, found %v", src, d)
+			}
+		default:
+			t.Errorf("%q: expected the declaration to be kept, found %T", src, d)
+		}
+	}
+
+	// A declaration broken before its head is not
+	file, err := ParseFile(utils.NewFileSet(), "partial.seal", "enum\ng = 2", AllErrors)
+	if err == nil || len(file.DeclList) != 2 {
+		t.Fatalf("Expected an error and 2 declarations, found %v and %v", err, file.DeclList)
+	}
+	if _, ok := file.DeclList[0].(*ast.BadDecl); !ok {
+		t.Errorf("Expected a BadDecl, found %T", file.DeclList[0])
+	}
+}
@@ -7,14 +7,19 @@ import (
 	"unicode/utf8"
 )
 
+// scanner modes
+const (
+	comments uint = 1 << iota // emit _Comment tokens instead of skipping comments
+)
+
 type scanner struct {
 	source
-	mode   uint
-	nlsemi bool // if set '\n' and EOF translate to ';'
+	mode uint
 
 	// current token, valid after calling next()
 	line, col uint
-	blank     bool // line is blank up to col
+	offs      int  // byte offset of the token start
+	blank     bool // line is blank up to col, i.e. token starts a line (see layout.go)
 	token     Token
 }
 
@@ -25,7 +30,6 @@ func (s *scanner) init(
 ) {
 	s.source.init(src, errh)
 	s.mode = mode
-	s.nlsemi = false
 }
 
 // errorf reports an error at the most recently read character position.
@@ -38,19 +42,29 @@ func (s *scanner) errorAtf(offset int, format string, args ...interface{}) {
 	s.errh(s.line, s.col+uint(offset), fmt.Sprintf(format, args...))
 }
 
+// next scans the next token and stamps it with its byte offsets.
 func (s *scanner) next() error {
+	err := s.scan()
+	s.token.start, s.token.end = s.offs, s.offset()
+	return err
+}
+
+func (s *scanner) scan() error {
 	s.start()
 	if s.end() {
-		s.token = Token{_EOF, ""}
+		s.offs = s.offset()
+		s.token = Token{tag: _EOF, lit: ""}
 		return nil
 	}
 	// Skip white space
 	startLine, startCol := s.pos()
+redo:
 	for s.ch == ' ' || s.ch == '\t' || s.ch == '\n' || s.ch == '\r' {
 		s.nextch()
 	}
 	// token start
 	s.line, s.col = s.pos()
+	s.offs = s.offset()
 	s.blank = s.line > startLine || startCol == colbase
 	s.start()
 
@@ -61,51 +75,86 @@ func (s *scanner) next() error {
 	}
 	switch s.ch {
 	case -1:
-		s.token = Token{_EOF, ""}
-
-	case '\n':
-		s.nextch()
-		s.token = Token{_Semi, "\n"}
+		s.token = Token{tag: _EOF, lit: ""}
 
 	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 		s.number(false)
 
 	case '(':
 		s.nextch()
-		s.token = Token{_ParentLeft, "("}
+		s.token = Token{tag: _ParentLeft, lit: "("}
 
 	case ')':
 		s.nextch()
-		s.token = Token{_ParentRight, ")"}
+		s.token = Token{tag: _ParentRight, lit: ")"}
 
 	case '{':
 		s.nextch()
-		s.token = Token{_BraceLeft, "{"}
+		if s.ch == '-' {
+			s.nextch()
+			s.blockComment()
+			if s.mode&comments == 0 {
+				goto redo
+			}
+			s.token = Token{tag: _Comment, lit: string(s.segment())}
+			break
+		}
+		s.token = Token{tag: _BraceLeft, lit: "{"}
+
+	case '-':
+		s.nextch()
+		if s.ch != '-' {
+			return s.symbol()
+		}
+		for s.ch == '-' {
+			s.nextch()
+		}
+		// `-->` is an operator, not a comment
+		if isOpChar(s.ch) {
+			return s.symbol()
+		}
+		s.lineComment()
+		if s.mode&comments == 0 {
+			goto redo
+		}
+		s.token = Token{tag: _Comment, lit: string(s.segment())}
 
 	case '}':
 		s.nextch()
-		s.token = Token{_BraceRight, "}"}
+		s.token = Token{tag: _BraceRight, lit: "}"}
 
 	case '\'':
-		s.nextch()
-		s.token = Token{_Quote, "'"}
+		s.rune()
 
 	case '"':
-		s.nextch()
-		s.token = Token{_DoubleQuote, `"`}
+		s.string()
 
 	case ';':
 		s.nextch()
-		s.token = Token{_Semi, ";"}
+		s.token = Token{tag: _Semi, lit: ";"}
+
+	case ',':
+		s.nextch()
+		s.token = Token{tag: _Comma, lit: ","}
+
+	case '[':
+		s.nextch()
+		s.token = Token{tag: _BracketLeft, lit: "["}
+
+	case ']':
+		s.nextch()
+		s.token = Token{tag: _BracketRight, lit: "]"}
+
+	case '`':
+		s.infixName()
 
 	default:
-		// fmt.Printf("%c\n", '('+5)
-		// s.errorf("invalid character %#U", s.ch)
-		// s.nextch()
-		// if unicode.IsSymbol(s.ch) {
-		return s.symbol()
-		// }
-		// return fmt.Errorf("invalid character %#U", s.ch)
+		if isOpChar(s.ch) {
+			return s.symbol()
+		}
+		s.errorf("invalid character %#U", s.ch)
+		s.nextch()
+		goto redo
 	}
 	return nil
 }
@@ -130,41 +179,90 @@ func (s *scanner) ident() error {
 
 	// possibly a keyword
 	lit := s.segment()
-	if string(lit) == "let" {
-		s.token = Token{_Let, "let"}
-		return nil
-	}
-	if string(lit) == "type" {
-		s.token = Token{_Type, "type"}
+	if tag, ok := keywords[string(lit)]; ok {
+		s.token = Token{tag: tag, lit: string(lit)}
 		return nil
 	}
 
-	s.nlsemi = true
-	s.token = Token{_Ident, string(lit)}
+	s.token = Token{tag: _Ident, lit: string(lit)}
 	return nil
 }
 
+// symbol scans an operator, i.e. a sequence of operator characters.
 func (s *scanner) symbol() error {
-	// for unicode.IsSymbol(s.ch) || isLetter(s.ch) || isDecimal(s.ch) {
-	// 	s.nextch()
-	// }
-	for !unicode.IsSpace(s.ch) && s.ch != '(' && s.ch != ')' {
+	for isOpChar(s.ch) {
 		s.nextch()
 	}
 	lit := string(s.segment())
-	switch lit {
-	case "=":
-		s.token = Token{_Assign, "="}
-	case ":":
-		s.token = Token{_Colon, ":"}
-	case "->":
-		s.token = Token{_Arrow, "->"}
-	default:
-		s.token = Token{_Symbol, string(lit)}
+	if tag, ok := reservedOps[lit]; ok {
+		s.token = Token{tag: tag, lit: lit}
+		return nil
 	}
+	s.token = Token{tag: _Symbol, lit: lit}
 	return nil
 }
 
+// infixName scans a backtick-quoted name used as an infix operator.
+func (s *scanner) infixName() {
+	s.nextch()
+	if !(isLetter(s.ch) || s.ch >= utf8.RuneSelf && s.atIdentChar(true)) {
+		s.errorf("expected name after '`'")
+	}
+	for isLetter(s.ch) || isDecimal(s.ch) || s.ch >= utf8.RuneSelf && s.atIdentChar(false) {
+		s.nextch()
+	}
+	if s.ch != '`' {
+		s.errorf("infix name not terminated")
+	} else {
+		s.nextch()
+	}
+	s.token = Token{tag: _InfixName, lit: string(s.segment())}
+}
+
+// isOpChar reports whether ch may appear in an operator such as `<$>`.
+func isOpChar(ch rune) bool {
+	switch ch {
+	case '!', '#', '$', '%', '&', '*', '+', '.', '/', '<', '=', '>', '?', '@', '\\', '^', '|', '-', '~', ':':
+		return true
+	}
+	return ch >= utf8.RuneSelf && (unicode.IsSymbol(ch) || unicode.IsPunct(ch))
+}
+
+// lineComment skips the rest of a `--` comment, up to but
+// excluding the terminating newline.
+func (s *scanner) lineComment() {
+	for s.ch != '\n' && s.ch >= 0 {
+		s.nextch()
+	}
+}
+
+// blockComment skips the rest of a `{- -}` comment after the
+// opening `{-`. Block comments nest.
+func (s *scanner) blockComment() {
+	depth := 1
+	for depth > 0 {
+		switch s.ch {
+		case -1:
+			s.errorAtf(0, "comment not terminated")
+			return
+		case '{':
+			s.nextch()
+			if s.ch == '-' {
+				s.nextch()
+				depth++
+			}
+		case '-':
+			s.nextch()
+			if s.ch == '}' {
+				s.nextch()
+				depth--
+			}
+		default:
+			s.nextch()
+		}
+	}
+}
+
 func (s *scanner) atIdentChar(first bool) bool {
 	switch {
 	case unicode.IsLetter(s.ch) || s.ch == '_':
@@ -173,6 +271,8 @@ func (s *scanner) atIdentChar(first bool) bool {
 		if first {
 			s.errorf("identifier cannot begin with digit %#U", s.ch)
 		}
+	case isOpChar(s.ch):
+		return false
 	case s.ch >= utf8.RuneSelf:
 		s.errorf("invalid character %#U in identifier", s.ch)
 	default:
@@ -259,7 +359,7 @@ func (s *scanner) number(seenPoint bool) {
 
 	// suffix 'i'
 	if s.ch == 'i' {
-		kind = _Comment
+		kind = _Complex
 		s.nextch()
 	}
 
@@ -280,6 +380,185 @@ func (s *scanner) number(seenPoint bool) {
 	// s.bad = !ok // correct s.bad
 }
 
+// string scans a string literal. A literal starting with three
+// double quotes is raw: it may span several lines and escape
+// sequences are not interpreted.
+func (s *scanner) string() {
+	ok := true
+	s.nextch()
+
+	if s.ch == '"' {
+		s.nextch()
+		if s.ch != '"' {
+			// Empty string ""
+			s.setLit(_String, ok)
+			return
+		}
+		s.nextch()
+		s.rawString()
+		return
+	}
+
+	for {
+		if s.ch == '"' {
+			s.nextch()
+			break
+		}
+		if s.ch == '\\' {
+			s.nextch()
+			if !s.escape('"') {
+				ok = false
+			}
+			continue
+		}
+		if s.ch == '\n' {
+			s.errorf("newline in string")
+			ok = false
+			break
+		}
+		if s.ch < 0 {
+			s.errorAtf(0, "string literal not terminated")
+			ok = false
+			break
+		}
+		s.nextch()
+	}
+
+	s.setLit(_String, ok)
+}
+
+// rawString scans the rest of a raw string after the opening """.
+func (s *scanner) rawString() {
+	ok := true
+	quotes := 0 // number of consecutive '"' read so far
+
+	for quotes < 3 {
+		if s.ch < 0 {
+			s.errorAtf(0, "raw string literal not terminated")
+			ok = false
+			break
+		}
+		if s.ch == '"' {
+			quotes++
+		} else {
+			quotes = 0
+		}
+		s.nextch()
+	}
+
+	s.setLit(_String, ok)
+}
+
+// rune scans a rune literal, i.e. a single character between quotes.
+func (s *scanner) rune() {
+	ok := true
+	s.nextch()
+
+	n := 0
+	for ; ; n++ {
+		if s.ch == '\'' {
+			if ok {
+				if n == 0 {
+					s.errorf("empty rune literal or unescaped ' in rune literal")
+					ok = false
+				} else if n != 1 {
+					s.errorAtf(0, "more than one character in rune literal")
+					ok = false
+				}
+			}
+			s.nextch()
+			break
+		}
+		if s.ch == '\\' {
+			s.nextch()
+			if !s.escape('\'') {
+				ok = false
+			}
+			continue
+		}
+		if s.ch == '\n' {
+			if ok {
+				s.errorf("newline in rune literal")
+				ok = false
+			}
+			break
+		}
+		if s.ch < 0 {
+			if ok {
+				s.errorAtf(0, "rune literal not terminated")
+				ok = false
+			}
+			break
+		}
+		s.nextch()
+	}
+
+	s.setLit(_Rune, ok)
+}
+
+// escape scans an escape sequence after the '\\', where quote is
+// the delimiter of the enclosing literal. It reports whether the
+// escape sequence is valid.
+func (s *scanner) escape(quote rune) bool {
+	var n int
+	var base, max uint32
+
+	switch s.ch {
+	case quote, 'a', 'b', 'f', 'n', 'r', 't', 'v', '\\':
+		s.nextch()
+		return true
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		n, base, max = 3, 8, 255
+	case 'x':
+		s.nextch()
+		n, base, max = 2, 16, 255
+	case 'u':
+		s.nextch()
+		n, base, max = 4, 16, unicode.MaxRune
+	case 'U':
+		s.nextch()
+		n, base, max = 8, 16, unicode.MaxRune
+	default:
+		if s.ch < 0 {
+			return true // complain in caller about EOF
+		}
+		s.errorf("unknown escape")
+		return false
+	}
+
+	var x uint32
+	for i := n; i > 0; i-- {
+		if s.ch < 0 {
+			return true // complain in caller about EOF
+		}
+		d := base
+		if isDecimal(s.ch) {
+			d = uint32(s.ch) - '0'
+		} else if 'a' <= lower(s.ch) && lower(s.ch) <= 'f' {
+			d = uint32(lower(s.ch)) - 'a' + 10
+		}
+		if d >= base {
+			s.errorf("invalid character %q in %s escape", s.ch, baseName(int(base)))
+			return false
+		}
+		// d < base
+		x = x*base + d
+		s.nextch()
+	}
+
+	if x > max && base == 8 {
+		s.errorf("octal escape value %d > 255", x)
+		return false
+	}
+
+	if x > max || 0xD800 <= x && x < 0xE000 /* surrogate range */ {
+		s.errorf("escape is invalid Unicode code point %#U", x)
+		return false
+	}
+
+	return true
+}
+
 func baseName(base int) string {
 	switch base {
 	case 2:
@@ -369,7 +648,6 @@ func (s *scanner) digits(base int, invalid *int) (digsep int) {
 
 // setLit sets the scanner state for a recognized _Literal token.
 func (s *scanner) setLit(tag tokenTag, ok bool) {
-	s.nlsemi = true
 	// s.bad = !ok
-	s.token = Token{tag, string(s.segment())}
+	s.token = Token{tag: tag, lit: string(s.segment())}
 }
@@ -71,7 +71,7 @@ func TestSamples(t *testing.T) {
 		}
 		token := s.token
 		// t.Logf("%v, %v\n", &token, &sample.token)
-		if !(token == sample.token) {
+		if !sameToken(token, sample.token) {
 			t.Errorf("Error of scanner: expected %#v, found %#v\n", sample.token, token)
 			return
 		}
@@ -93,61 +93,143 @@ func TestSamples(t *testing.T) {
 //  	_String                      // String with some encoding
 //  	_Let                         // Let binding
 //  	_Type                        // Type declaration
-//  	_Quote                       // ' single quote
-//  	_DoubleQuote                 // Double quote "
 //  	_Comment                     // Comment
 //  	_Semi                        // ';' or '\n'
 //  	_EOF                         // End Of File
 // )
 
+// sameToken compares tokens regardless of their position.
+func sameToken(a, b Token) bool {
+	return a.tag == b.tag && a.lit == b.lit
+}
+
 type sample struct {
 	raw   string
 	token Token
 }
 
 var signSamples = [...]sample{
-	{"(", Token{_ParentLeft, "("}},
-	{")", Token{_ParentRight, ")"}},
-	{"{", Token{_BraceLeft, "{"}},
-	{"}", Token{_BraceRight, "}"}},
-	{")", Token{_ParentRight, ")"}},
-	{"'", Token{_Quote, "'"}},
-	{"\"", Token{_DoubleQuote, "\""}},
-	{";", Token{_Semi, ";"}},
-	{"=", Token{_Assign, "="}},
-	{"->", Token{_Arrow, "->"}},
+	{"(", Token{tag: _ParentLeft, lit: "("}},
+	{")", Token{tag: _ParentRight, lit: ")"}},
+	{"{", Token{tag: _BraceLeft, lit: "{"}},
+	{"}", Token{tag: _BraceRight, lit: "}"}},
+	{")", Token{tag: _ParentRight, lit: ")"}},
+	{";", Token{tag: _Semi, lit: ";"}},
+	{"=", Token{tag: _Assign, lit: "="}},
+	{"->", Token{tag: _Arrow, lit: "->"}},
+	{"=>", Token{tag: _FatArrow, lit: "=>"}},
+	{"<-", Token{tag: _LeftArrow, lit: "<-"}},
+	{"|", Token{tag: _Bar, lit: "|"}},
+	{"\\", Token{tag: _Backslash, lit: "\\"}},
+	{".", Token{tag: _Dot, lit: "."}},
+	{"..", Token{tag: _DotDot, lit: ".."}},
+	{",", Token{tag: _Comma, lit: ","}},
+	{"[", Token{tag: _BracketLeft, lit: "["}},
+	{"]", Token{tag: _BracketRight, lit: "]"}},
+	{"`div`", Token{tag: _InfixName, lit: "`div`"}},
+	{"`除`", Token{tag: _InfixName, lit: "`除`"}},
 }
 
 var keywordSamples = [...]sample{
-	{"let", Token{_Let, "let"}},
+	{"seal", Token{tag: _Seal, lit: "seal"}},
+	{"let", Token{tag: _Let, lit: "let"}},
+	{"type", Token{tag: _Type, lit: "type"}},
+	{"module", Token{tag: _Module, lit: "module"}},
+	{"import", Token{tag: _Import, lit: "import"}},
+	{"enum", Token{tag: _Enum, lit: "enum"}},
+	{"impl", Token{tag: _Impl, lit: "impl"}},
+	{"case", Token{tag: _Case, lit: "case"}},
+	{"of", Token{tag: _Of, lit: "of"}},
+	{"where", Token{tag: _Where, lit: "where"}},
+	{"in", Token{tag: _In, lit: "in"}},
+	{"do", Token{tag: _Do, lit: "do"}},
+	{"if", Token{tag: _If, lit: "if"}},
+	{"then", Token{tag: _Then, lit: "then"}},
+	{"else", Token{tag: _Else, lit: "else"}},
+	{"forall", Token{tag: _Forall, lit: "forall"}},
+	{"infixl", Token{tag: _Infixl, lit: "infixl"}},
+	{"infixr", Token{tag: _Infixr, lit: "infixr"}},
+	{"infix", Token{tag: _Infix, lit: "infix"}},
+	{"Type", Token{tag: _Ident, lit: "Type"}},
+	{"seals", Token{tag: _Ident, lit: "seals"}},
 }
 
 func identifierSamples() []sample {
 	ans := []sample{
-		{"a", Token{_Ident, "a"}},
-		{"a1", Token{_Ident, "a1"}},
-		{"test", Token{_Ident, "test"}},
-		{"test09", Token{_Ident, "test09"}},
-		{"test_me", Token{_Ident, "test_me"}},
-		{"变量", Token{_Ident, "变量"}},
-		{"命运石之门", Token{_Ident, "命运石之门"}},
+		{"a", Token{tag: _Ident, lit: "a"}},
+		{"a1", Token{tag: _Ident, lit: "a1"}},
+		{"test", Token{tag: _Ident, lit: "test"}},
+		{"test09", Token{tag: _Ident, lit: "test09"}},
+		{"test_me", Token{tag: _Ident, lit: "test_me"}},
+		{"变量", Token{tag: _Ident, lit: "变量"}},
+		{"命运石之门", Token{tag: _Ident, lit: "命运石之门"}},
 	}
 	return ans
 }
 
 func symbolSamples() []sample {
 	ans := []sample{
-		{"<>", Token{_Symbol, "<>"}},
-		{"-", Token{_Symbol, "-"}},
-		{"++", Token{_Symbol, "++"}},
-		{"@>", Token{_Symbol, "@>"}},
-		{"==", Token{_Symbol, "=="}},
-		{"!=", Token{_Symbol, "!="}},
-		{"::", Token{_Symbol, "::"}},
+		{"<>", Token{tag: _Symbol, lit: "<>"}},
+		{"-", Token{tag: _Symbol, lit: "-"}},
+		{"++", Token{tag: _Symbol, lit: "++"}},
+		{"@>", Token{tag: _Symbol, lit: "@>"}},
+		{"==", Token{tag: _Symbol, lit: "=="}},
+		{"!=", Token{tag: _Symbol, lit: "!="}},
+		{"::", Token{tag: _Symbol, lit: "::"}},
+		{"<$>", Token{tag: _Symbol, lit: "<$>"}},
+		{">>=", Token{tag: _Symbol, lit: ">>="}},
+		{"||", Token{tag: _Symbol, lit: "||"}},
+		{"$", Token{tag: _Symbol, lit: "$"}},
+		{"∘", Token{tag: _Symbol, lit: "∘"}},
 	}
 	return ans
 }
 
+func literalSamples() []sample {
+	ans := []sample{
+		{`""`, Token{tag: _String, lit: `""`}},
+		{`"Hello, world!"`, Token{tag: _String, lit: `"Hello, world!"`}},
+		{`"a\n\t\"b\""`, Token{tag: _String, lit: `"a\n\t\"b\""`}},
+		{`"\x41\u1234\U0001F600\101"`, Token{tag: _String, lit: `"\x41\u1234\U0001F600\101"`}},
+		{`"烤红薯"`, Token{tag: _String, lit: `"烤红薯"`}},
+		{`"""raw \n "quoted"
+multi-line"""`, Token{tag: _String, lit: `"""raw \n "quoted"
+multi-line"""`}},
+		{`'a'`, Token{tag: _Rune, lit: `'a'`}},
+		{`'\''`, Token{tag: _Rune, lit: `'\''`}},
+		{`'\n'`, Token{tag: _Rune, lit: `'\n'`}},
+		{`'\x41'`, Token{tag: _Rune, lit: `'\x41'`}},
+		{`'\u1234'`, Token{tag: _Rune, lit: `'\u1234'`}},
+		{`'薯'`, Token{tag: _Rune, lit: `'薯'`}},
+	}
+	return ans
+}
+
+func TestLiteralErrors(t *testing.T) {
+	bad := []string{
+		`"unterminated`,
+		"\"new\nline\"",
+		`"\q"`,
+		`"\x4"`,
+		`"\uD800"`,
+		`"""raw`,
+		`''`,
+		`'ab'`,
+		`'a`,
+	}
+	for _, src := range bad {
+		s := scanner{}
+		errs := 0
+		s.init(bytes.NewReader([]byte(src)), func(r, c uint, msg string) {
+			errs++
+		}, 0)
+		s.next()
+		if errs == 0 {
+			t.Errorf("Expected an error scanning %q, found %v", src, &s.token)
+		}
+	}
+}
+
 func integerSamples(limit int) []sample {
 	ans := []sample{}
 
@@ -155,7 +237,7 @@ func integerSamples(limit int) []sample {
 		return fmt.Sprintf("%v", x)
 	}
 	for i := 0; i < limit; i++ {
-		ans = append(ans, sample{str(i), Token{_Integer, str(i)}})
+		ans = append(ans, sample{str(i), Token{tag: _Integer, lit: str(i)}})
 	}
 	return ans
 }
@@ -165,7 +247,7 @@ func floatSamples(limit int) []sample {
 	for i := 0; i < limit; i++ {
 		num := rand.Float64()
 		s := fmt.Sprintf("%v", num)
-		ans = append(ans, sample{s, Token{_Float, s}})
+		ans = append(ans, sample{s, Token{tag: _Float, lit: s}})
 	}
 	return ans
 }
@@ -176,7 +258,113 @@ func samples() []sample {
 	ans = append(ans, keywordSamples[:]...)
 	ans = append(ans, identifierSamples()[:]...)
 	ans = append(ans, symbolSamples()[:]...)
+	ans = append(ans, literalSamples()[:]...)
 	ans = append(ans, integerSamples(100)[:]...)
 	ans = append(ans, floatSamples(100)[:]...)
 	return ans
 }
+
+func TestComments(t *testing.T) {
+	data := []byte("a -- line comment\n{- block {- nested -} -} b --> c {-| doc -}")
+	want := []Token{
+		{tag: _Ident, lit: "a"},
+		{tag: _Ident, lit: "b"},
+		{tag: _Symbol, lit: "-->"},
+		{tag: _Ident, lit: "c"},
+		{tag: _EOF, lit: ""},
+	}
+	s := newScanner(t, bytes.NewReader(data))
+	for _, tok := range want {
+		s.next()
+		if !sameToken(s.token, tok) {
+			t.Errorf("Expected %v, found %v", &tok, &s.token)
+		}
+	}
+
+	// Keep comments
+	want = []Token{
+		{tag: _Ident, lit: "a"},
+		{tag: _Comment, lit: "-- line comment"},
+		{tag: _Comment, lit: "{- block {- nested -} -}"},
+		{tag: _Ident, lit: "b"},
+		{tag: _Symbol, lit: "-->"},
+		{tag: _Ident, lit: "c"},
+		{tag: _Comment, lit: "{-| doc -}"},
+		{tag: _EOF, lit: ""},
+	}
+	s = newScanner(t, bytes.NewReader(data))
+	s.mode = comments
+	for _, tok := range want {
+		s.next()
+		if !sameToken(s.token, tok) {
+			t.Errorf("Expected %v, found %v", &tok, &s.token)
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	cases := []struct {
+		src  string
+		want []Token
+	}{
+		{"x::xs", []Token{{tag: _Ident, lit: "x"}, {tag: _Symbol, lit: "::"}, {tag: _Ident, lit: "xs"}}},
+		{"(x :: xs)", []Token{{tag: _ParentLeft, lit: "("}, {tag: _Ident, lit: "x"}, {tag: _Symbol, lit: "::"}, {tag: _Ident, lit: "xs"}, {tag: _ParentRight, lit: ")"}}},
+		{"{id = 0}", []Token{{tag: _BraceLeft, lit: "{"}, {tag: _Ident, lit: "id"}, {tag: _Assign, lit: "="}, {tag: _Integer, lit: "0"}, {tag: _BraceRight, lit: "}"}}},
+		{"a,b", []Token{{tag: _Ident, lit: "a"}, {tag: _Comma, lit: ","}, {tag: _Ident, lit: "b"}}},
+		{"\\a -> a", []Token{{tag: _Backslash, lit: "\\"}, {tag: _Ident, lit: "a"}, {tag: _Arrow, lit: "->"}, {tag: _Ident, lit: "a"}}},
+		{"(<>)}", []Token{{tag: _ParentLeft, lit: "("}, {tag: _Symbol, lit: "<>"}, {tag: _ParentRight, lit: ")"}, {tag: _BraceRight, lit: "}"}}},
+		{"[a,b]", []Token{{tag: _BracketLeft, lit: "["}, {tag: _Ident, lit: "a"}, {tag: _Comma, lit: ","}, {tag: _Ident, lit: "b"}, {tag: _BracketRight, lit: "]"}}},
+		{"a `div` b", []Token{{tag: _Ident, lit: "a"}, {tag: _InfixName, lit: "`div`"}, {tag: _Ident, lit: "b"}}},
+		{"x <- m", []Token{{tag: _Ident, lit: "x"}, {tag: _LeftArrow, lit: "<-"}, {tag: _Ident, lit: "m"}}},
+		{"Eq a => a", []Token{{tag: _Ident, lit: "Eq"}, {tag: _Ident, lit: "a"}, {tag: _FatArrow, lit: "=>"}, {tag: _Ident, lit: "a"}}},
+		{"Ref.run", []Token{{tag: _Ident, lit: "Ref"}, {tag: _Dot, lit: "."}, {tag: _Ident, lit: "run"}}},
+		{"a|b", []Token{{tag: _Ident, lit: "a"}, {tag: _Bar, lit: "|"}, {tag: _Ident, lit: "b"}}},
+		{"变量∘x", []Token{{tag: _Ident, lit: "变量"}, {tag: _Symbol, lit: "∘"}, {tag: _Ident, lit: "x"}}},
+	}
+	for _, c := range cases {
+		s := newScanner(t, bytes.NewReader([]byte(c.src)))
+		for _, tok := range append(c.want, Token{tag: _EOF, lit: ""}) {
+			s.next()
+			if !sameToken(s.token, tok) {
+				t.Errorf("Scanning %q: expected %v, found %v", c.src, &tok, &s.token)
+				break
+			}
+		}
+	}
+}
+
+func TestOffsets(t *testing.T) {
+	data := []byte("fact n =\n  \"烤\" + n -- done\n")
+	want := []struct {
+		lit        string
+		start, end int
+	}{
+		{"fact", 0, 4},
+		{"n", 5, 6},
+		{"=", 7, 8},
+		{`"烤"`, 11, 16},
+		{"+", 17, 18},
+		{"n", 19, 20},
+		{"", 29, 29},
+	}
+	s := newScanner(t, bytes.NewReader(data))
+	for _, w := range want {
+		s.next()
+		if s.token.lit != w.lit || s.token.start != w.start || s.token.end != w.end {
+			t.Errorf("Expected %q at [%d, %d), found %q at [%d, %d)",
+				w.lit, w.start, w.end, s.token.lit, s.token.start, s.token.end)
+		}
+	}
+}
+
+func TestOffsetsAcrossRefill(t *testing.T) {
+	data := bytes.Repeat([]byte("x "), 10000)
+	data = append(data, "end"...)
+	s := newScanner(t, bytes.NewReader(data))
+	for s.token.lit != "end" && s.token.tag != _EOF {
+		s.next()
+	}
+	if s.token.start != 20000 || s.token.end != 20003 {
+		t.Errorf("Expected `end` at [20000, 20003), found [%d, %d)", s.token.start, s.token.end)
+	}
+}
@@ -46,6 +46,7 @@ type source struct {
 	buf       []byte // source buffer
 	ioerr     error  // pending I/O error, or nil
 	b, r, e   int    // buffer indices (see comment above)
+	base      int    // number of bytes dropped from the front of buf
 	line, col uint   // source position of ch (0-based)
 	ch        rune   // most recently read character
 	chw       int    // width of ch
@@ -63,6 +64,7 @@ func (s *source) init(in io.Reader, errh func(line, col uint, msg string)) {
 	s.buf[0] = sentinel
 	s.ioerr = nil
 	s.b, s.r, s.e = -1, 0, 0
+	s.base = 0
 	s.line, s.col = 0, 0
 	s.ch = ' '
 	s.chw = 0
@@ -77,6 +79,11 @@ func (s *source) pos() (line, col uint) {
 	return linebase + s.line, colbase + s.col
 }
 
+// offset returns the byte offset of s.ch from the start of the input.
+func (s *source) offset() int {
+	return s.base + s.r - s.chw
+}
+
 // error reports the error msg at source position s.pos().
 func (s *source) error(msg string) {
 	line, col := s.pos()
@@ -181,6 +188,7 @@ func (s *source) fill() {
 	}
 	s.r -= b
 	s.e -= b
+	s.base += b
 
 	// read more data: try a limited number of times
 	for i := 0; i < 10; i++ {
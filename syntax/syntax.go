@@ -0,0 +1,60 @@
+package syntax
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/seal-script/sealing/ast"
+	"github.com/seal-script/sealing/utils"
+)
+
+// A Mode controls how much ParseFile does
+type Mode uint
+
+const (
+	KeepComments Mode = 1 << iota // attach doc comments to declarations
+	Trace                         // print a trace of the parsed productions
+	AllErrors                     // report all errors, not just the first 10
+)
+
+// Parsing stops after errorLimit errors unless AllErrors is set
+const errorLimit = 10
+
+// ParseFile parses the source of a single file and registers it
+// in fset. The source may be a string, a []byte or an io.Reader;
+// if src is nil the file named filename is read instead.
+//
+// The returned file may be incomplete when there are errors, with
+// BadDecl and BadExpr nodes standing in for the broken parts. The
+// error is then an ErrorList, sorted by location.
+func ParseFile(fset *utils.FileSet, filename string, src any, mode Mode) (*ast.File, error) {
+	text, err := readSource(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	p := new(Parser)
+	p.Init(fset.AddFile(filename, text), nil, mode)
+	p.next() // Fill buffer
+	return p.ParseFile()
+}
+
+func readSource(filename string, src any) ([]byte, error) {
+	switch s := src.(type) {
+	case nil:
+		return os.ReadFile(filename)
+	case string:
+		return []byte(s), nil
+	case []byte:
+		return s, nil
+	case *bytes.Buffer:
+		// is io.Reader, but src is already available in []byte form
+		if s != nil {
+			return s.Bytes(), nil
+		}
+	case io.Reader:
+		return io.ReadAll(s)
+	}
+	return nil, errors.New("invalid source")
+}
@@ -5,29 +5,53 @@ import "fmt"
 type tokenTag int
 
 const (
-	_Seal        tokenTag = iota // 'seal'
-	_ParentLeft                  // Left '('
-	_ParentRight                 // Right ')'
-	_BraceLeft                   // Left '{'
-	_BraceRight                  // Right '}'
-	_Ident                       // Identifier
-	_Integer                     // Integer lit
-	_Float                       // Float number lit
-	_Complex                     // Complex number lit
-	_Rune                        // A single unicode character
-	_String                      // String with some encoding
-	_Let                         // Let binding
-	_Type                        // Type declaration
-	_Quote                       // ' single quote
-	_DoubleQuote                 // Double quote "
-	_Comment                     // Comment
-	_Semi                        // ';' or '\n'
-	_Colon                       // ':'
-	_Assign                      // '='
-	_Minus                       // '-'
-	_Arrow                       // '->'
-	_Symbol                      // symbols, e.g., == != >=
-	_EOF                         // End Of File
+	_Seal         tokenTag = iota // 'seal'
+	_ParentLeft                   // Left '('
+	_ParentRight                  // Right ')'
+	_BraceLeft                    // Left '{'
+	_BraceRight                   // Right '}'
+	_Ident                        // Identifier
+	_Integer                      // Integer lit
+	_Float                        // Float number lit
+	_Complex                      // Complex number lit
+	_Rune                         // Rune lit, e.g. 'a' or '\n'
+	_String                       // String lit, e.g. "abc" or """raw"""
+	_Let                          // Let binding
+	_Type                         // Type declaration
+	_Module                       // 'module'
+	_Import                       // 'import'
+	_Enum                         // 'enum'
+	_Impl                         // 'impl'
+	_Case                         // 'case'
+	_Of                           // 'of'
+	_Where                        // 'where'
+	_In                           // 'in'
+	_Do                           // 'do'
+	_If                           // 'if'
+	_Then                         // 'then'
+	_Else                         // 'else'
+	_Forall                       // 'forall'
+	_Infixl                       // 'infixl'
+	_Infixr                       // 'infixr'
+	_Infix                        // 'infix'
+	_Comment                      // Comment
+	_Semi                         // ';' or '\n'
+	_Colon                        // ':'
+	_Assign                       // '='
+	_Minus                        // '-'
+	_Arrow                        // '->'
+	_FatArrow                     // '=>'
+	_LeftArrow                    // '<-'
+	_Bar                          // '|'
+	_Backslash                    // '\\'
+	_Dot                          // '.'
+	_DotDot                       // '..'
+	_Comma                        // ','
+	_BracketLeft                  // Left '['
+	_BracketRight                 // Right ']'
+	_InfixName                    // backtick-quoted name, e.g. `div`
+	_Symbol                       // symbols, e.g., == != >=
+	_EOF                          // End Of File
 )
 
 func (tag tokenTag) String() string {
@@ -65,6 +89,54 @@ func (tag tokenTag) String() string {
 	case _Type:
 		return "Type"
 
+	case _Module:
+		return "Module"
+
+	case _Import:
+		return "Import"
+
+	case _Enum:
+		return "Enum"
+
+	case _Impl:
+		return "Impl"
+
+	case _Case:
+		return "Case"
+
+	case _Of:
+		return "Of"
+
+	case _Where:
+		return "Where"
+
+	case _In:
+		return "In"
+
+	case _Do:
+		return "Do"
+
+	case _If:
+		return "If"
+
+	case _Then:
+		return "Then"
+
+	case _Else:
+		return "Else"
+
+	case _Forall:
+		return "Forall"
+
+	case _Infixl:
+		return "Infixl"
+
+	case _Infixr:
+		return "Infixr"
+
+	case _Infix:
+		return "Infix"
+
 	case _Comment:
 		return "Comment"
 
@@ -77,18 +149,42 @@ func (tag tokenTag) String() string {
 	case _Assign:
 		return "Assign"
 
-	case _Quote:
-		return "Quote"
-
-	case _DoubleQuote:
-		return "DoubleQuote"
-
 	case _Arrow:
 		return "Arrow"
 
 	case _Minus:
 		return "Minus"
 
+	case _FatArrow:
+		return "FatArrow"
+
+	case _LeftArrow:
+		return "LeftArrow"
+
+	case _Bar:
+		return "Bar"
+
+	case _Backslash:
+		return "Backslash"
+
+	case _Dot:
+		return "Dot"
+
+	case _DotDot:
+		return "DotDot"
+
+	case _Comma:
+		return "Comma"
+
+	case _BracketLeft:
+		return "BracketLeft"
+
+	case _BracketRight:
+		return "BracketRight"
+
+	case _InfixName:
+		return "InfixName"
+
 	case _Symbol:
 		return "Symbol"
 
@@ -106,9 +202,46 @@ func (tag tokenTag) String() string {
 	}
 }
 
+// Keywords of SealScript, none of them can be used as an identifier
+var keywords = map[string]tokenTag{
+	"seal":   _Seal,
+	"let":    _Let,
+	"type":   _Type,
+	"module": _Module,
+	"import": _Import,
+	"enum":   _Enum,
+	"impl":   _Impl,
+	"case":   _Case,
+	"of":     _Of,
+	"where":  _Where,
+	"in":     _In,
+	"do":     _Do,
+	"if":     _If,
+	"then":   _Then,
+	"else":   _Else,
+	"forall": _Forall,
+	"infixl": _Infixl,
+	"infixr": _Infixr,
+	"infix":  _Infix,
+}
+
+// Operators with a meaning of their own, they cannot be redefined
+var reservedOps = map[string]tokenTag{
+	"=":  _Assign,
+	":":  _Colon,
+	"->": _Arrow,
+	"=>": _FatArrow,
+	"<-": _LeftArrow,
+	"|":  _Bar,
+	"\\": _Backslash,
+	".":  _Dot,
+	"..": _DotDot,
+}
+
 type Token struct {
-	tag tokenTag
-	lit string
+	tag        tokenTag
+	lit        string
+	start, end int // byte offsets of the token, end is exclusive
 }
 
 // func (tok Token) String() string {
@@ -118,3 +251,9 @@ type Token struct {
 func (tok *Token) String() string {
 	return fmt.Sprintf("{%s, \"%s\"}", tok.tag.String(), tok.lit)
 }
+
+// isKeyword reports whether tok is a keyword.
+func (tok *Token) isKeyword() bool {
+	tag, ok := keywords[tok.lit]
+	return ok && tag == tok.tag
+}
@@ -1,3 +1,13 @@
 package typecheck
 
-type Checker struct{}
+import "github.com/seal-script/sealing/utils"
+
+// A Checker checks the files of one compilation, all of
+// them registered in the same FileSet as the parser used.
+type Checker struct {
+	Fset *utils.FileSet
+}
+
+func NewChecker(fset *utils.FileSet) *Checker {
+	return &Checker{Fset: fset}
+}
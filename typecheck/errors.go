@@ -0,0 +1,9 @@
+package typecheck
+
+import "github.com/seal-script/sealing/utils"
+
+// An Error is a semantic error found while checking a module
+type Error = utils.Error
+
+// An ErrorList collects every Error found in a module.
+type ErrorList = utils.ErrorList
@@ -0,0 +1,140 @@
+package typecheck
+
+import (
+	"fmt"
+
+	"github.com/seal-script/sealing/ast"
+)
+
+// Exports is what a module offers to its importers: the exported
+// top-level names, and for every exported enum or seal the
+// constructors or methods exported along with it.
+type Exports struct {
+	Module  string
+	Names   map[string]ast.Decl // exported names and their declarations
+	Members map[string][]string // exported members, by their enum or seal
+}
+
+// Has reports whether the module exports name, either as a
+// top-level name or as a member of an exported enum or seal.
+func (e *Exports) Has(name string) bool {
+	_, ok := e.Names[name]
+	return ok
+}
+
+// topLevel holds the top-level names declared in a file
+type topLevel struct {
+	decls   map[string]ast.Decl
+	members map[string][]*ast.Name // constructors or methods, by their owner
+	owners  map[string]string      // owner, by the name of the member
+}
+
+func collectTopLevel(file *ast.File) *topLevel {
+	top := &topLevel{
+		decls:   map[string]ast.Decl{},
+		members: map[string][]*ast.Name{},
+		owners:  map[string]string{},
+	}
+	for _, decl := range file.DeclList {
+		switch d := decl.(type) {
+		case *ast.TypeDecl:
+			if _, ok := top.decls[d.Name.Value]; !ok {
+				top.decls[d.Name.Value] = d
+			}
+		case *ast.FuncDecl:
+			if _, ok := top.decls[d.Name.Value]; !ok {
+				top.decls[d.Name.Value] = d
+			}
+		case *ast.EnumDecl:
+			// The enum declares the type, not its signature
+			top.decls[d.Name.Value] = d
+			for i := range d.Cons {
+				top.addMember(d.Name.Value, d.Cons[i].Name, d)
+			}
+		case *ast.SealDecl:
+			top.decls[d.Name.Value] = d
+			for i := range d.Fields {
+				top.addMember(d.Name.Value, d.Fields[i].Name, d)
+			}
+		case *ast.ImplDecl:
+			// Only named instances can be referred to
+			if d.Name != nil {
+				top.decls[d.Name.Value] = d
+			}
+		}
+	}
+	return top
+}
+
+func (top *topLevel) addMember(owner string, member *ast.Name, decl ast.Decl) {
+	top.members[owner] = append(top.members[owner], member)
+	top.owners[member.Value] = owner
+	top.decls[member.Value] = decl
+}
+
+// Exports checks the export list of the module header against the
+// top-level names of the file, and returns what the module exports.
+// A file without an export list exports every top-level name.
+func (c *Checker) Exports(file *ast.File) (*Exports, error) {
+	top := collectTopLevel(file)
+	exports := &Exports{
+		Names:   map[string]ast.Decl{},
+		Members: map[string][]string{},
+	}
+	if file.PkgName != nil {
+		exports.Module = file.PkgName.Value
+	}
+
+	if file.Module == nil || file.Module.ExportList == nil {
+		for name, decl := range top.decls {
+			exports.Names[name] = decl
+		}
+		for owner, members := range top.members {
+			for _, member := range members {
+				exports.Members[owner] = append(exports.Members[owner], member.Value)
+			}
+		}
+		return exports, nil
+	}
+
+	errs := ErrorList{}
+	errorf := func(n ast.Node, format string, args ...any) {
+		errs = append(errs, Error{Location: n.Locate(), Msg: fmt.Sprintf(format, args...)})
+	}
+	for _, spec := range file.Module.ExportList {
+		name := spec.Name.Value
+		decl, ok := top.decls[name]
+		if !ok {
+			errorf(spec.Name, "%s is not declared in module %s", name, exports.Module)
+			continue
+		}
+		if owner, ok := top.owners[name]; ok {
+			errorf(spec.Name, "%s must be exported as %s(%s) or %s(..)", name, owner, name, owner)
+			continue
+		}
+		exports.Names[name] = decl
+
+		members := top.members[name]
+		if (spec.All || spec.Members != nil) && members == nil {
+			errorf(spec.Name, "%s has no constructors or methods to export", name)
+			continue
+		}
+		switch {
+		case spec.All:
+			for _, member := range members {
+				exports.Names[member.Value] = top.decls[member.Value]
+				exports.Members[name] = append(exports.Members[name], member.Value)
+			}
+		case spec.Members != nil:
+			for _, member := range spec.Members {
+				if top.owners[member.Value] != name {
+					errorf(member, "%s is not a constructor or method of %s", member.Value, name)
+					continue
+				}
+				exports.Names[member.Value] = top.decls[member.Value]
+				exports.Members[name] = append(exports.Members[name], member.Value)
+			}
+		}
+	}
+	return exports, errs.Err()
+}
@@ -0,0 +1,77 @@
+package typecheck
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/seal-script/sealing/ast"
+	"github.com/seal-script/sealing/syntax"
+	"github.com/seal-script/sealing/utils"
+)
+
+func exportsOf(t *testing.T, src string) (*Exports, error) {
+	fset := utils.NewFileSet()
+	file, err := syntax.ParseFile(fset, "exports.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// enum Bool { True : Bool; False : Bool }
+	file.DeclList = append(file.DeclList, &ast.EnumDecl{
+		Name: &ast.Name{Value: "Bool"},
+		Cons: []ast.TypeDecl{
+			{Name: &ast.Name{Value: "True"}},
+			{Name: &ast.Name{Value: "False"}},
+		},
+	})
+	return NewChecker(fset).Exports(file)
+}
+
+func names(e *Exports) string {
+	ns := []string{}
+	for name := range e.Names {
+		ns = append(ns, name)
+	}
+	sort.Strings(ns)
+	return strings.Join(ns, " ")
+}
+
+func TestExports(t *testing.T) {
+	cases := []struct {
+		src, want string
+	}{
+		{"f x = x\ng = f", "Bool False True f g"},
+		{"module m (f)\nf x = x\ng = f", "f"},
+		{"module m (f, Bool)\nf x = x", "Bool f"},
+		{"module m (Bool(..))\nf x = x", "Bool False True"},
+		{"module m (Bool(True))\nf x = x", "Bool True"},
+		{"module m (Show(..))\nseal Show a { show : a -> String }", "Show show"},
+	}
+	for _, c := range cases {
+		e, err := exportsOf(t, c.src)
+		if err != nil {
+			t.Errorf("Checking %q: %v", c.src, err)
+			continue
+		}
+		if got := names(e); got != c.want {
+			t.Errorf("Checking %q: expected exports %q, found %q", c.src, c.want, got)
+		}
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	cases := []struct {
+		src, msg string
+	}{
+		{"module m (h)\nf x = x", "h is not declared in module m"},
+		{"module m (True)\nf x = x", "True must be exported as Bool(True) or Bool(..)"},
+		{"module m (f(..))\nf x = x", "f has no constructors or methods to export"},
+		{"module m (Bool(Maybe))\nf x = x", "Maybe is not a constructor or method of Bool"},
+	}
+	for _, c := range cases {
+		_, err := exportsOf(t, c.src)
+		if err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("Checking %q: expected error %q, found %v", c.src, c.msg, err)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package typecheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seal-script/sealing/ast"
+)
+
+// records holds the constructors of the enums in scope
+type records struct {
+	enums  map[string]*ast.EnumDecl // by name
+	cons   map[string]*ast.TypeDecl // every constructor
+	owners map[string]*ast.EnumDecl // enum, by the name of the constructor
+	fields map[string][]ast.Field   // fields of the record constructors
+	having map[string][]string      // record constructors, by the name of their fields
+	errs   ErrorList
+}
+
+func (r *records) addEnum(d *ast.EnumDecl) {
+	if _, ok := r.enums[d.Name.Value]; ok {
+		return
+	}
+	r.enums[d.Name.Value] = d
+	for i := range d.Cons {
+		con := &d.Cons[i]
+		r.cons[con.Name.Value] = con
+		r.owners[con.Name.Value] = d
+		// New : { id : Int, name : String } -> Person
+		_, params, _ := ast.UncurryType(con.Type)
+		if len(params) != 1 {
+			continue
+		}
+		if record, ok := params[0].(*ast.RecordType); ok {
+			r.fields[con.Name.Value] = record.Fields
+			for _, field := range record.Fields {
+				r.having[field.Name.Value] = append(r.having[field.Name.Value], con.Name.Value)
+			}
+		}
+	}
+}
+
+func (r *records) errorf(n ast.Node, format string, args ...any) {
+	r.errs = append(r.errs, Error{Location: n.Locate(), Msg: fmt.Sprintf(format, args...)})
+}
+
+// CheckRecords checks the records built, updated, selected and matched
+// in a file against the record constructors of its enums and of the
+// enums of its imports: a record must be given every field of its
+// constructor once, other uses must name fields which exist, and the
+// fields given a literal or a constructor must have its type.
+func (c *Checker) CheckRecords(file *ast.File, imports ...*Exports) error {
+	r := &records{
+		enums:  map[string]*ast.EnumDecl{},
+		cons:   map[string]*ast.TypeDecl{},
+		owners: map[string]*ast.EnumDecl{},
+		fields: map[string][]ast.Field{},
+		having: map[string][]string{},
+	}
+	for _, decl := range file.DeclList {
+		if d, ok := decl.(*ast.EnumDecl); ok {
+			r.addEnum(d)
+		}
+	}
+	for _, exports := range imports {
+		for _, decl := range exports.Names {
+			if d, ok := decl.(*ast.EnumDecl); ok {
+				r.addEnum(d)
+			}
+		}
+	}
+	for _, decl := range file.DeclList {
+		r.checkDecl(decl)
+	}
+	return r.errs.Err()
+}
+
+func (r *records) checkDecl(decl ast.Decl) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		for _, clause := range d.Clauses {
+			r.checkPatterns(clause.Params)
+			r.checkExpr(clause.Body)
+		}
+	case *ast.SealDecl:
+		for _, def := range d.Defaults {
+			r.checkDecl(def)
+		}
+	case *ast.ImplDecl:
+		for _, method := range d.Methods {
+			r.checkDecl(method)
+		}
+		if d.Value != nil {
+			r.checkExpr(d.Value)
+		}
+	}
+}
+
+func (r *records) checkExpr(expr ast.Expr) {
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		r.checkExpr(e.Fun)
+		r.checkExprs(e.ArgList)
+	case *ast.InfixExpr:
+		r.checkExprs(e.Exprs)
+	case *ast.NegExpr:
+		r.checkExpr(e.X)
+	case *ast.SectionExpr:
+		if e.Left != nil {
+			r.checkExpr(e.Left)
+		}
+		if e.Right != nil {
+			r.checkExpr(e.Right)
+		}
+	case *ast.LambdaExpr:
+		r.checkPatterns(e.Params)
+		r.checkExpr(e.Body)
+	case *ast.LetExpr:
+		for _, decl := range e.Decls {
+			r.checkDecl(decl)
+		}
+		r.checkExpr(e.Body)
+	case *ast.WhereExpr:
+		r.checkExpr(e.Body)
+		for _, decl := range e.Decls {
+			r.checkDecl(decl)
+		}
+	case *ast.IfExpr:
+		r.checkExprs([]ast.Expr{e.Cond, e.Then, e.Else})
+	case *ast.CaseExpr:
+		r.checkExpr(e.X)
+		for _, alt := range e.Alts {
+			r.checkPattern(alt.Pattern)
+			r.checkExpr(alt.Body)
+		}
+	case *ast.GuardedExpr:
+		for _, alt := range e.Alts {
+			for _, guard := range alt.Guards {
+				if guard.Pattern != nil {
+					r.checkPattern(guard.Pattern)
+				}
+				r.checkExpr(guard.X)
+			}
+			r.checkExpr(alt.Body)
+		}
+	case *ast.DoExpr:
+		for _, stmt := range e.Stmts {
+			switch s := stmt.(type) {
+			case *ast.BindStmt:
+				r.checkPattern(s.Pattern)
+				r.checkExpr(s.X)
+			case *ast.LetStmt:
+				for _, decl := range s.Decls {
+					r.checkDecl(decl)
+				}
+			case *ast.ExprStmt:
+				r.checkExpr(s.X)
+			}
+		}
+
+	case *ast.SelectorExpr:
+		r.checkExpr(e.X)
+		if e.Qualified {
+			// A name qualified by its module or seal
+			return
+		}
+		if len(r.having[e.Sel.Value]) == 0 {
+			r.errorf(e.Sel, "No record has a field %s", e.Sel)
+		}
+	case *ast.RecordExpr:
+		r.checkRecord(e)
+	case *ast.UpdateExpr:
+		r.checkExpr(e.X)
+		names := []*ast.Name{}
+		for _, field := range e.Fields {
+			names = append(names, field.Name)
+			if field.Value != nil {
+				r.checkExpr(field.Value)
+			}
+		}
+		r.checkFieldNames(e, names)
+	}
+}
+
+func (r *records) checkExprs(exprs []ast.Expr) {
+	for _, e := range exprs {
+		r.checkExpr(e)
+	}
+}
+
+// checkRecord checks that a record is given every field of its
+// constructor once, and values of the right type.
+func (r *records) checkRecord(e *ast.RecordExpr) {
+	con := r.conOf(e.Con)
+	if con == nil {
+		return
+	}
+	fields := r.fields[con.Value]
+	given := map[string]bool{}
+	for _, field := range e.Fields {
+		if field.Value != nil {
+			r.checkExpr(field.Value)
+		}
+		if given[field.Name.Value] {
+			r.errorf(field.Name, "Field %s of %s is given twice", field.Name, con)
+			continue
+		}
+		given[field.Name.Value] = true
+		decl := fieldOf(fields, field.Name.Value)
+		if decl == nil {
+			r.errorf(field.Name, "%s has no field %s", con, field.Name)
+			continue
+		}
+		if field.Value == nil {
+			continue
+		}
+		want, got := typeName(decl.Type), r.typeOf(field.Value)
+		if want != "" && got != "" && want != got {
+			r.errorf(field.Value, "Field %s of %s has type %s, found %v of type %s", field.Name, con, want, field.Value, got)
+		}
+	}
+	missing := []string{}
+	for _, field := range fields {
+		if !given[field.Name.Value] {
+			missing = append(missing, field.Name.Value)
+		}
+	}
+	if len(missing) > 0 {
+		r.errorf(e, "Missing fields of %s: %s", con, strings.Join(missing, ", "))
+	}
+}
+
+// conOf returns the record constructor of `New` or `Person.New`,
+// or nil after reporting why there is none.
+func (r *records) conOf(x ast.Expr) *ast.Name {
+	var con *ast.Name
+	switch x := x.(type) {
+	case *ast.Name:
+		con = x
+	case *ast.SelectorExpr:
+		con = x.Sel
+		if enum, ok := x.X.(*ast.Name); ok && r.enums[enum.Value] != nil {
+			if owner := r.owners[con.Value]; owner == nil || owner.Name.Value != enum.Value {
+				r.errorf(con, "%s is not a constructor of %s", con, enum)
+				return nil
+			}
+		}
+	default:
+		r.errorf(x, "Expected a record constructor, found %v", x)
+		return nil
+	}
+	if _, ok := r.fields[con.Value]; !ok {
+		if _, ok := r.cons[con.Value]; ok {
+			r.errorf(con, "%s is not a record constructor", con)
+		} else {
+			r.errorf(con, "Unknown record constructor %s", con)
+		}
+		return nil
+	}
+	return con
+}
+
+// checkFieldNames checks that some record has all the fields
+func (r *records) checkFieldNames(n ast.Node, names []*ast.Name) {
+	var cons []string // the records having all the fields so far
+	for i, name := range names {
+		having := r.having[name.Value]
+		if len(having) == 0 {
+			r.errorf(name, "No record has a field %s", name)
+			return
+		}
+		if i == 0 {
+			cons = having
+			continue
+		}
+		common := []string{}
+		for _, con := range cons {
+			for _, other := range having {
+				if con == other {
+					common = append(common, con)
+				}
+			}
+		}
+		cons = common
+	}
+	if len(names) > 0 && len(cons) == 0 {
+		s := make([]string, len(names))
+		for i, name := range names {
+			s[i] = name.Value
+		}
+		r.errorf(n, "No record has all the fields %s", strings.Join(s, ", "))
+	}
+}
+
+func (r *records) checkPatterns(patterns []ast.Pattern) {
+	for _, p := range patterns {
+		r.checkPattern(p)
+	}
+}
+
+func (r *records) checkPattern(pattern ast.Pattern) {
+	switch p := pattern.(type) {
+	case *ast.ConPattern:
+		r.checkPatterns(p.Args)
+	case *ast.AsPattern:
+		r.checkPattern(p.Pattern)
+	case *ast.TypedPattern:
+		r.checkPattern(p.Pattern)
+	case *ast.InfixPattern:
+		r.checkPatterns(p.Patterns)
+	case *ast.RecordPattern:
+		names := []*ast.Name{}
+		for _, field := range p.Fields {
+			names = append(names, field.Name)
+			if field.Pattern != nil {
+				r.checkPattern(field.Pattern)
+			}
+		}
+		if p.Con == nil {
+			r.checkFieldNames(p, names)
+			return
+		}
+		// Patterns need not match every field
+		con := r.conOf(p.Con)
+		if con == nil {
+			return
+		}
+		for _, name := range names {
+			if fieldOf(r.fields[con.Value], name.Value) == nil {
+				r.errorf(name, "%s has no field %s", con, name)
+			}
+		}
+	}
+}
+
+// typeOf returns the name of the type of literals, of nullary
+// constructors and of records, or "" for other expressions.
+func (r *records) typeOf(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Name:
+		con, ok := r.cons[e.Value]
+		if !ok {
+			return ""
+		}
+		if _, params, _ := ast.UncurryType(con.Type); len(params) > 0 {
+			return ""
+		}
+		return r.owners[e.Value].Name.Value
+	case *ast.RecordExpr:
+		var con *ast.Name
+		switch x := e.Con.(type) {
+		case *ast.Name:
+			con = x
+		case *ast.SelectorExpr:
+			con = x.Sel
+		}
+		if con == nil || r.owners[con.Value] == nil {
+			return ""
+		}
+		return r.owners[con.Value].Name.Value
+	case *ast.Integer:
+		return "Int"
+	case *ast.Float:
+		return "Float"
+	case *ast.String:
+		return "String"
+	case *ast.Rune:
+		return "Rune"
+	}
+	return ""
+}
+
+// typeName returns the name of a type without parameters, or ""
+func typeName(t ast.Type) string {
+	if t, ok := t.(*ast.CallExpr); ok && len(t.ArgList) == 0 {
+		if name, ok := t.Fun.(*ast.Name); ok && ast.IsConName(name.Value) {
+			return name.Value
+		}
+	}
+	return ""
+}
+
+func fieldOf(fields []ast.Field, name string) *ast.Field {
+	for i := range fields {
+		if fields[i].Name.Value == name {
+			return &fields[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package typecheck
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/seal-script/sealing/syntax"
+	"github.com/seal-script/sealing/utils"
+)
+
+const people = `
+enum Person {
+    New { id : Int, name : String, role : Role }
+    Anon
+}
+enum Role { Admin; User }
+enum Point { P { x : Int, y : Int } }
+`
+
+func TestCheckRecords(t *testing.T) {
+	cases := []struct {
+		src, err string // err is "" when the source checks
+	}{
+		{`tom = Person.New { id = 0  name = "Tom"  role = User }`, ""},
+		{"tom id name = New { id, name, role = Admin }", ""},
+		{"rename p = p { name = \"Tim\" }\nid p = p.id", ""},
+		{"f (New { id = 0 }) = 0\nf { x, y } = x", ""},
+		{
+			`tom = New { id = 0, name = "Tom" }`,
+			"records.seal:8:7: Missing fields of New: role",
+		},
+		{
+			`tom = New { id = 0, name = "Tom", role = User, age = 4 }`,
+			"records.seal:8:48: New has no field age",
+		},
+		{
+			`tom = New { id = 0, id = 1, name = "Tom", role = User }`,
+			"records.seal:8:21: Field id of New is given twice",
+		},
+		{
+			`tom = New { id = "0", name = "Tom", role = User }`,
+			"records.seal:8:18: Field id of New has type Int, found \"0\" of type String",
+		},
+		{
+			`tom = New { id = 0, name = "Tom", role = Anon }`,
+			"records.seal:8:42: Field role of New has type Role, found Anon of type Person",
+		},
+		{"anon = Anon { id = 0 }", "records.seal:8:8: Anon is not a record constructor"},
+		{"p = Role.P { x = 0, y = 0 }", "records.seal:8:10: P is not a constructor of Role"},
+		{"p = Q { x = 0 }", "records.seal:8:5: Unknown record constructor Q"},
+		{"f p = p.age", "records.seal:8:9: No record has a field age"},
+		{"f p = p { x = 0, name = \"\" }", "records.seal:8:7: No record has all the fields x, name"},
+		{"f (P { z }) = z", "records.seal:8:8: P has no field z"},
+	}
+	for _, c := range cases {
+		fset := utils.NewFileSet()
+		file, err := syntax.ParseFile(fset, "records.seal", people+c.src, 0)
+		if err != nil {
+			t.Fatalf("Parsing %q: %v", c.src, err)
+		}
+		err = NewChecker(fset).CheckRecords(file)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("Checking %q: %v", c.src, err)
+		case c.err != "" && err == nil:
+			t.Errorf("Checking %q: expected an error", c.src)
+		case c.err != "" && !strings.HasPrefix(err.Error(), c.err):
+			t.Errorf("Checking %q: expected %q, found %q", c.src, c.err, err)
+		}
+	}
+}
+
+func TestCheckImportedRecords(t *testing.T) {
+	fset := utils.NewFileSet()
+	dep, err := syntax.ParseFile(fset, "people.seal", "module People (Person(..))"+people, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exports, err := NewChecker(fset).Exports(dep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := syntax.ParseFile(fset, "main.seal", "tom = New { id = 0 }", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "main.seal:1:7: Missing fields of New: name, role"
+	if err := NewChecker(fset).CheckRecords(file, exports); err == nil || err.Error() != want {
+		t.Errorf("Expected %q, found %v", want, err)
+	}
+
+	// A record named like a module is still a record
+	dep, err = syntax.ParseFile(fset, "config.seal", "module config (Config(..))\nenum Config { C { port : Int } }", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exports, err = NewChecker(fset).Exports(dep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := "import config\n\nport config = config.port\nhost config = config.host\n"
+	file, err = syntax.ParseFile(fset, "main.seal", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "main.seal:4:22: No record has a field host"
+	if err := NewChecker(fset).CheckRecords(file, exports); err == nil || err.Error() != want {
+		t.Errorf("Expected %q, found %v", want, err)
+	}
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+)
+
+// An Error is an error found at a location of a source file
+type Error struct {
+	Location Location
+	Msg      string
+}
+
+// Errorf returns an Error at loc with a message formatted by fmt.Sprintf.
+func Errorf(loc Location, format string, args ...any) Error {
+	return Error{Location: loc, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (err Error) Error() string {
+	loc := err.Location
+	return fmt.Sprintf("%s:%d:%d: %s", loc.FilePath, loc.Line, loc.Col, err.Msg)
+}
+
+// An ErrorList collects the errors found in one or more files,
+// by the parser and the checker alike.
+type ErrorList []Error
+
+func (list ErrorList) Len() int      { return len(list) }
+func (list ErrorList) Swap(i, j int) { list[i], list[j] = list[j], list[i] }
+func (list ErrorList) Less(i, j int) bool {
+	a, b := list[i].Location, list[j].Location
+	if a.FilePath != b.FilePath {
+		return a.FilePath < b.FilePath
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Col < b.Col
+}
+
+// Sort sorts the errors by location, keeping the order of
+// errors found at the same location.
+func (list ErrorList) Sort() {
+	sort.Stable(list)
+}
+
+func (list ErrorList) Error() string {
+	switch len(list) {
+	case 0:
+		return "no errors"
+	case 1:
+		return list[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", list[0].Error(), len(list)-1)
+}
+
+// Err sorts the list and returns it as an error, or nil if it is empty.
+func (list ErrorList) Err() error {
+	if len(list) == 0 {
+		return nil
+	}
+	list.Sort()
+	return list
+}
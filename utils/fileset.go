@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Pos is a compact source position: the base of a file within
+// its FileSet plus a byte offset into that file. The zero value
+// NoPos is not a position of any file.
+type Pos int
+
+const NoPos Pos = 0
+
+// IsValid reports whether the position belongs to some file.
+func (p Pos) IsValid() bool { return p != NoPos }
+
+// A File is a source file registered in a FileSet. The text
+// is kept around so diagnostics can quote the offending line.
+type File struct {
+	name  string
+	base  int
+	src   []byte
+	lines []int // offsets of the first byte of each line
+}
+
+func (f *File) Name() string   { return f.name }
+func (f *File) Base() int      { return f.base }
+func (f *File) Size() int      { return len(f.src) }
+func (f *File) Source() []byte { return f.src }
+func (f *File) LineCount() int { return len(f.lines) }
+
+// Pos returns the position of the byte offset offs of the file.
+func (f *File) Pos(offs int) Pos {
+	if offs < 0 || offs > len(f.src) {
+		panic("invalid file offset")
+	}
+	return Pos(f.base + offs)
+}
+
+// Offset returns the byte offset of the position p of the file.
+func (f *File) Offset(p Pos) int {
+	if int(p) < f.base || int(p) > f.base+len(f.src) {
+		panic("invalid Pos value")
+	}
+	return int(p) - f.base
+}
+
+// Location returns the (file, line, col) triple of the byte offset offs.
+// Lines and columns start at 1, columns count bytes.
+func (f *File) Location(offs int) Location {
+	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offs }) - 1
+	return Location{
+		FilePath: f.name,
+		Line:     uint(i + 1),
+		Col:      uint(offs - f.lines[i] + 1),
+	}
+}
+
+// LineText returns the text of the given line, without the newline.
+func (f *File) LineText(line uint) string {
+	if line < 1 || int(line) > len(f.lines) {
+		return ""
+	}
+	start := f.lines[line-1]
+	end := len(f.src)
+	if int(line) < len(f.lines) {
+		end = f.lines[line] - 1
+	}
+	return strings.TrimSuffix(string(f.src[start:end]), "\r")
+}
+
+// A FileSet holds every source file of a compilation, and gives
+// each of them a disjoint range of positions.
+type FileSet struct {
+	mutex sync.RWMutex
+	base  int // base of the next file
+	files []*File
+	last  *File // cache of the last file looked up
+}
+
+func NewFileSet() *FileSet {
+	return &FileSet{base: 1}
+}
+
+// AddFile registers the source of the file with the given name.
+func (s *FileSet) AddFile(filename string, src []byte) *File {
+	f := &File{name: filename, src: src, lines: []int{0}}
+	for i, b := range src {
+		if b == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f.base = s.base
+	s.base += len(src) + 1 // +1 so that the end of file is a position as well
+	s.files = append(s.files, f)
+	return f
+}
+
+// File returns the file holding the position p, or nil.
+func (s *FileSet) File(p Pos) *File {
+	s.mutex.RLock()
+	last := s.last
+	s.mutex.RUnlock()
+	if last != nil && last.base <= int(p) && int(p) <= last.base+len(last.src) {
+		return last
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].base > int(p) }) - 1
+	if i < 0 {
+		return nil
+	}
+	f := s.files[i]
+	if int(p) > f.base+len(f.src) {
+		return nil
+	}
+	s.last = f
+	return f
+}
+
+// Files returns the registered files in the order they were added.
+func (s *FileSet) Files() []*File {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return append([]*File(nil), s.files...)
+}
+
+// Location turns the position p into a (file, line, col) triple.
+func (s *FileSet) Location(p Pos) Location {
+	f := s.File(p)
+	if f == nil {
+		return Location{}
+	}
+	return f.Location(f.Offset(p))
+}
+
+// Excerpt quotes the line of the position p, with a caret under its column.
+func (s *FileSet) Excerpt(p Pos) string {
+	f := s.File(p)
+	if f == nil {
+		return ""
+	}
+	loc := f.Location(f.Offset(p))
+	text := f.LineText(loc.Line)
+	col := int(loc.Col) - 1
+	if col > len(text) {
+		col = len(text)
+	}
+	indent := []byte(text[:col])
+	for i, b := range indent {
+		if b != '\t' {
+			indent[i] = ' '
+		}
+	}
+	return text + "\n" + string(indent) + "^"
+}
@@ -0,0 +1,40 @@
+package utils
+
+import "testing"
+
+func TestFileSet(t *testing.T) {
+	fset := NewFileSet()
+	a := fset.AddFile("a.seal", []byte("x = 1\nf y =\n\ty\n"))
+	b := fset.AddFile("b.seal", []byte("main = f x"))
+
+	cases := []struct {
+		pos  Pos
+		want Location
+	}{
+		{a.Pos(0), Location{"a.seal", 1, 1}},
+		{a.Pos(4), Location{"a.seal", 1, 5}},
+		{a.Pos(6), Location{"a.seal", 2, 1}},
+		{a.Pos(13), Location{"a.seal", 3, 2}},
+		{a.Pos(a.Size()), Location{"a.seal", 4, 1}},
+		{b.Pos(0), Location{"b.seal", 1, 1}},
+		{b.Pos(9), Location{"b.seal", 1, 10}},
+	}
+	for _, c := range cases {
+		if got := fset.Location(c.pos); got != c.want {
+			t.Errorf("Location of %d: expected %v, found %v", c.pos, c.want, got)
+		}
+	}
+	if fset.File(NoPos) != nil {
+		t.Errorf("Expected no file at NoPos")
+	}
+	if fset.File(b.Pos(3)) != b {
+		t.Errorf("Expected b.seal to hold its own positions")
+	}
+
+	if got, want := fset.Excerpt(a.Pos(13)), "\ty\n\t^"; got != want {
+		t.Errorf("Expected excerpt %q, found %q", want, got)
+	}
+	if got, want := fset.Excerpt(b.Pos(7)), "main = f x\n       ^"; got != want {
+		t.Errorf("Expected excerpt %q, found %q", want, got)
+	}
+}
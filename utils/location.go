@@ -4,3 +4,14 @@ type Location struct {
 	FilePath  string
 	Line, Col uint
 }
+
+// A Span is the half-open range [Start, End) of a piece of source,
+// see FileSet for turning its positions into locations.
+type Span struct {
+	Start, End Pos
+}
+
+// Contains reports whether the position p lies within the span.
+func (span Span) Contains(p Pos) bool {
+	return span.Start <= p && p < span.End
+}